@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/models"
+	"github.com/yousifsabah0/snippets/internal/validators"
+)
+
+const (
+	apiDefaultPageSize = 20
+	apiMaxPageSize     = 100
+)
+
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	page, err := queryInt(r, "page", 1)
+	if err != nil || page < 1 {
+		app.apiError(w, r, http.StatusBadRequest, "page must be a positive integer")
+		return
+	}
+
+	pageSize, err := queryInt(r, "page_size", apiDefaultPageSize)
+	if err != nil || pageSize < 1 || pageSize > apiMaxPageSize {
+		app.apiError(w, r, http.StatusBadRequest, fmt.Sprintf("page_size must be between 1 and %d", apiMaxPageSize))
+		return
+	}
+
+	tag := r.URL.Query().Get("tag")
+	if tag != "" && !validators.Matches(tag, validators.TagRX) {
+		app.apiError(w, r, http.StatusBadRequest, "tag is not a valid tag")
+		return
+	}
+
+	// Ask for one extra snippet to find out whether another page follows.
+	snippets, err := app.snippets.Recent(r.Context(), tag, pageSize+1, (page-1)*pageSize)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	hasMore := len(snippets) > pageSize
+	if hasMore {
+		snippets = snippets[:pageSize]
+	}
+
+	metadata := envelope{"page": page, "page_size": pageSize, "has_more": hasMore}
+	if err := app.writeJSON(w, http.StatusOK, envelope{"snippets": snippets, "metadata": metadata}, nil); err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
+
+func (app *application) apiSnippetView(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		app.apiClientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	snippet, err := app.snippets.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiClientError(w, r, http.StatusNotFound)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	if !snippet.VisibleTo(app.authenticatedUserID(r)) {
+		app.apiClientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	// API clients have no session to unlock snippets in, so they send the
+	// password with every request.
+	if snippet.Protected() && snippet.UserID != app.authenticatedUserID(r) {
+		password := r.Header.Get("X-Snippet-Password")
+		if password == "" {
+			app.apiError(w, r, http.StatusUnauthorized, "snippet is password protected, send its password in the X-Snippet-Password header")
+			return
+		}
+
+		ok, err := app.checkSnippetPassword(r, snippet, password)
+		if errors.Is(err, errUnlockThrottled) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(unlockWindow.Seconds())))
+			app.apiError(w, r, http.StatusTooManyRequests, "too many wrong snippet passwords, try again later")
+			return
+		}
+
+		if !ok {
+			app.apiError(w, r, http.StatusUnauthorized, "wrong snippet password")
+			return
+		}
+	}
+
+	// API clients are not link previews, so reading a snippet with a view
+	// limit counts as a view straight away.
+	if snippet.MaxViews > 0 {
+		snippet, err = app.snippets.View(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.apiClientError(w, r, http.StatusNotFound)
+			} else {
+				app.apiServerError(w, r, err)
+			}
+			return
+		}
+	}
+
+	app.metrics.snippetsViewed.Inc()
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"snippet": snippet}, nil); err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
+
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	var form snippetCreateForm
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.apiError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	form.validate()
+	form.validateExpiry(app.snippetPolicy)
+
+	if !form.Valid() {
+		app.apiValidationError(w, r, form.Validator)
+		return
+	}
+
+	draft := form.draft()
+	draft.Expires = form.expires(time.Now())
+
+	id, err := app.snippets.Insert(r.Context(), app.authenticatedUserID(r), draft)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	app.metrics.snippetsCreated.Inc()
+
+	snippet, err := app.snippets.Get(r.Context(), id)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/snippets/%d", id))
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"snippet": snippet}, headers); err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
+
+// queryInt reads an integer query string parameter, falling back to def when
+// it is missing.
+func queryInt(r *http.Request, key string, def int) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return def, nil
+	}
+
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/assert"
+	"github.com/yousifsabah0/snippets/internal/models/snippets"
+	"github.com/yousifsabah0/snippets/internal/models/tokens"
+)
+
+func TestAPISnippetCreate(t *testing.T) {
+	app, _ := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	if err := app.users.Insert(t.Context(), "Alice", "alice@example.com", "pa55word"); err != nil {
+		t.Fatal(err)
+	}
+
+	readWrite, err := app.tokens.New(t.Context(), 1, "ci", []string{tokens.ScopeRead, tokens.ScopeWrite}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readOnly, err := app.tokens.New(t.Context(), 1, "dashboard", []string{tokens.ScopeRead}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		body       string
+		wantCode   int
+		wantErrors map[string]string
+	}{
+		{
+			name:     "Valid",
+			token:    readWrite.Plaintext,
+			body:     `{"title": "Deploy", "content": "make deploy", "expires": 7}`,
+			wantCode: http.StatusCreated,
+		},
+		{
+			name:     "Tagged",
+			token:    readWrite.Plaintext,
+			body:     `{"title": "Deploy", "content": "make deploy", "tags": ["K8s", "bash"], "expires": 7}`,
+			wantCode: http.StatusCreated,
+		},
+		{
+			name:     "Language",
+			token:    readWrite.Plaintext,
+			body:     `{"title": "Deploy", "content": "make deploy", "language": "Makefile", "expires": 7}`,
+			wantCode: http.StatusCreated,
+		},
+		{
+			name:     "Unsupported language",
+			token:    readWrite.Plaintext,
+			body:     `{"title": "Deploy", "content": "make deploy", "language": "COBOL", "expires": 7}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantErrors: map[string]string{
+				"language": "This field must be a supported language",
+			},
+		},
+		{
+			name:     "Invalid visibility",
+			token:    readWrite.Plaintext,
+			body:     `{"title": "Deploy", "content": "make deploy", "visibility": "secret", "expires": 7}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantErrors: map[string]string{
+				"visibility": "This field must equal public, unlisted, or private",
+			},
+		},
+		{
+			name:     "Invalid view limit",
+			token:    readWrite.Plaintext,
+			body:     `{"title": "Deploy", "content": "make deploy", "max_views": -1, "expires": 7}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantErrors: map[string]string{
+				"max_views": "This field must be between 0 and 100",
+			},
+		},
+		{
+			name:     "Short password",
+			token:    readWrite.Plaintext,
+			body:     `{"title": "Deploy", "content": "make deploy", "password": "abc", "expires": 7}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantErrors: map[string]string{
+				"password": "This field must be at least 8 characters long",
+			},
+		},
+		{
+			name:     "Encrypted",
+			token:    readWrite.Plaintext,
+			body:     `{"title": "Deploy", "content": "Cr6eXPFIenZ22xO9dqkX7Lf9j1ryorys1VpsT3ZhXoNDs5W8SOZWUg==", "encrypted": true, "expires": 7}`,
+			wantCode: http.StatusCreated,
+		},
+		{
+			name:     "Encrypted plaintext",
+			token:    readWrite.Plaintext,
+			body:     `{"title": "Deploy", "content": "make deploy", "encrypted": true, "expires": 7}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantErrors: map[string]string{
+				"content": "This field must be base64-encoded ciphertext",
+			},
+		},
+		{
+			name:     "Invalid tags",
+			token:    readWrite.Plaintext,
+			body:     `{"title": "Deploy", "content": "make deploy", "tags": ["a b/c"], "expires": 7}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantErrors: map[string]string{
+				"tags": "Tags can only contain letters, digits, and . _ + -",
+			},
+		},
+		{
+			name:     "Long content",
+			token:    readWrite.Plaintext,
+			body:     `{"title": "Deploy", "content": "` + strings.Repeat("a", maxContent+1) + `", "expires": 7}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantErrors: map[string]string{
+				"content": "This field cannot be more than 100000 characters long",
+			},
+		},
+		{
+			name:     "Invalid",
+			token:    readWrite.Plaintext,
+			body:     `{"title": "", "content": "make deploy", "expires": 400}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantErrors: map[string]string{
+				"title":   "This field is required",
+				"expires": "This field cannot be more than 1 year",
+			},
+		},
+		{
+			name:     "Expires in units",
+			token:    readWrite.Plaintext,
+			body:     `{"title": "Deploy", "content": "make deploy", "expires_in": 90, "expires_unit": "minutes"}`,
+			wantCode: http.StatusCreated,
+		},
+		{
+			name:     "Never expires",
+			token:    readWrite.Plaintext,
+			body:     `{"title": "Deploy", "content": "make deploy", "expires_unit": "never"}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantErrors: map[string]string{
+				"expires": "This field must equal minutes, hours, days, weeks, or years",
+			},
+		},
+		{
+			name:     "Unknown field",
+			token:    readWrite.Plaintext,
+			body:     `{"title": "Deploy", "body": "make deploy"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Missing scope",
+			token:    readOnly.Plaintext,
+			body:     `{"title": "Deploy", "content": "make deploy", "expires": 7}`,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Bad token",
+			token:    "snp_nope",
+			body:     `{"title": "Deploy", "content": "make deploy", "expires": 7}`,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "No token",
+			body:     `{"title": "Deploy", "content": "make deploy", "expires": 7}`,
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/snippets", strings.NewReader(test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+
+			code, header, body := ts.do(t, req)
+
+			assert.Equal(t, code, test.wantCode)
+			assert.Equal(t, header.Get("Content-Type"), "application/json")
+
+			if test.wantErrors != nil {
+				var got struct {
+					Errors map[string]string `json:"errors"`
+				}
+
+				if err := json.Unmarshal([]byte(body), &got); err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Equal(t, len(got.Errors), len(test.wantErrors))
+				for key, message := range test.wantErrors {
+					assert.Equal(t, got.Errors[key], message)
+				}
+			}
+		})
+	}
+}
+
+func TestAPISnippetRead(t *testing.T) {
+	app, _ := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	if err := app.users.Insert(t.Context(), "Alice", "alice@example.com", "pa55word"); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []int
+	for _, title := range []string{"Deploy", "Rollback", "Restart"} {
+		id, err := app.snippets.Insert(t.Context(), 1, snippets.Draft{Title: title, Content: "make " + strings.ToLower(title), Visibility: snippets.Public, Expires: time.Now().AddDate(0, 0, 7)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	readOnly, err := app.tokens.New(t.Context(), 1, "dashboard", []string{tokens.ScopeRead}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "List",
+			token:    readOnly.Plaintext,
+			urlPath:  "/api/v1/snippets",
+			wantCode: http.StatusOK,
+			wantBody: `"has_more":false`,
+		},
+		{
+			name:     "Page",
+			token:    readOnly.Plaintext,
+			urlPath:  "/api/v1/snippets?page=1&page_size=2",
+			wantCode: http.StatusOK,
+			wantBody: `"has_more":true`,
+		},
+		{
+			name:     "Invalid page",
+			token:    readOnly.Plaintext,
+			urlPath:  "/api/v1/snippets?page=0",
+			wantCode: http.StatusBadRequest,
+			wantBody: "page must be a positive integer",
+		},
+		{
+			name:     "Page too large",
+			token:    readOnly.Plaintext,
+			urlPath:  "/api/v1/snippets?page_size=101",
+			wantCode: http.StatusBadRequest,
+			wantBody: "page_size must be between 1 and 100",
+		},
+		{
+			name:     "View",
+			token:    readOnly.Plaintext,
+			urlPath:  "/api/v1/snippets/" + strconv.Itoa(ids[1]),
+			wantCode: http.StatusOK,
+			wantBody: `"title":"Rollback"`,
+		},
+		{
+			name:     "Non-existent ID",
+			token:    readOnly.Plaintext,
+			urlPath:  "/api/v1/snippets/" + strconv.Itoa(ids[2]+1),
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "String ID",
+			token:    readOnly.Plaintext,
+			urlPath:  "/api/v1/snippets/foo",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "No token",
+			urlPath:  "/api/v1/snippets",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+test.urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+
+			code, header, body := ts.do(t, req)
+
+			assert.Equal(t, code, test.wantCode)
+			assert.Equal(t, header.Get("Content-Type"), "application/json")
+			if test.wantBody != "" {
+				assert.StringContains(t, body, test.wantBody)
+			}
+		})
+	}
+}
+
+func TestAPISnippetPassword(t *testing.T) {
+	app, _ := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	for _, name := range []string{"Alice", "Bob"} {
+		if err := app.users.Insert(t.Context(), name, strings.ToLower(name)+"@example.com", "pa55word"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	id, err := app.snippets.Insert(t.Context(), 1, snippets.Draft{Title: "Database password", Content: "hunter2", Visibility: snippets.Public, Password: "open sesame", Expires: time.Now().AddDate(0, 0, 7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice, err := app.tokens.New(t.Context(), 1, "ci", []string{tokens.ScopeRead}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bob, err := app.tokens.New(t.Context(), 2, "ci", []string{tokens.ScopeRead}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(token, path, password string) (int, string) {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+		if password != "" {
+			req.Header.Set("X-Snippet-Password", password)
+		}
+
+		code, _, body := ts.do(t, req)
+
+		return code, body
+	}
+
+	code, body := get(bob.Plaintext, "/api/v1/snippets", "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Database password")
+	assert.Equal(t, strings.Contains(body, "hunter2"), false)
+
+	path := "/api/v1/snippets/" + strconv.Itoa(id)
+
+	code, body = get(bob.Plaintext, path, "")
+	assert.Equal(t, code, http.StatusUnauthorized)
+	assert.Equal(t, strings.Contains(body, "hunter2"), false)
+
+	code, body = get(bob.Plaintext, path, "close sesame")
+	assert.Equal(t, code, http.StatusUnauthorized)
+	assert.Equal(t, strings.Contains(body, "hunter2"), false)
+
+	code, body = get(bob.Plaintext, path, "open sesame")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "hunter2")
+	assert.Equal(t, strings.Contains(body, "$2a$"), false)
+
+	code, body = get(alice.Plaintext, path, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "hunter2")
+}
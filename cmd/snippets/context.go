@@ -2,4 +2,12 @@ package main
 
 type contextKey string
 
-const isAuthenticatedContextKey = contextKey("isAuthenticated")
+const (
+	isAuthenticatedContextKey = contextKey("isAuthenticated")
+	snippetContextKey         = contextKey("snippet")
+	authIDContextKey          = contextKey("authID")
+	tokenContextKey           = contextKey("token")
+	requestIDContextKey       = contextKey("requestID")
+	loggerContextKey          = contextKey("logger")
+	middlewareSpanContextKey  = contextKey("middlewareSpan")
+)
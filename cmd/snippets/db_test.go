@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/yousifsabah0/snippets/internal/assert"
+)
+
+func TestOpenDBRequiresSQLiteDSN(t *testing.T) {
+	_, err := openDB("sqlite", "")
+	if err == nil {
+		t.Fatal("want an error for sqlite without a DSN")
+	}
+	assert.Equal(t, err.Error(), "the sqlite database driver needs -dsn")
+}
+
+func TestMySQLDSN(t *testing.T) {
+	cfg, err := mysql.ParseDSN(mysqlDSN("odyssey:odyssey@/snippets?parseTime=true"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, cfg.ClientFoundRows, true)
+	assert.Equal(t, cfg.ParseTime, true)
+}
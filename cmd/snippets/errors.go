@@ -1,8 +1,12 @@
 package main
 
 import (
+	"bytes"
 	"net/http"
 	"runtime/debug"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/validators"
 )
 
 func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
@@ -12,10 +16,85 @@ func (app *application) serverError(w http.ResponseWriter, r *http.Request, err
 		trace  = string(debug.Stack())
 	)
 
-	app.logger.Error(err.Error(), "method", method, "uri", uri, "trace", trace)
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	app.requestLogger(r).Error(err.Error(), "method", method, "uri", uri, "trace", trace)
+
+	// The error page quotes the request ID so that users can pass it on.
+	// It is rendered without newTemplateData, as the session may be what
+	// failed, and falls back to plain text if even that does not work.
+	data := templateData{
+		CurrentYear: time.Now().Year(),
+		RequestID:   requestIDFromContext(r),
+	}
+
+	buf := new(bytes.Buffer)
+	if ts, ok := app.templateCace["error.html"]; !ok || ts.ExecuteTemplate(buf, "index", data) != nil {
+		message := http.StatusText(http.StatusInternalServerError)
+		if data.RequestID != "" {
+			message += "\nRequest ID: " + data.RequestID
+		}
+
+		http.Error(w, message, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	buf.WriteTo(w)
 }
 
 func (app *application) clientError(w http.ResponseWriter, status int) {
 	http.Error(w, http.StatusText(status), status)
 }
+
+// apiServerError is the JSON equivalent of serverError, used by /api routes.
+func (app *application) apiServerError(w http.ResponseWriter, r *http.Request, err error) {
+	var (
+		method = r.Method
+		uri    = r.URL.RequestURI()
+		trace  = string(debug.Stack())
+	)
+
+	app.requestLogger(r).Error(err.Error(), "method", method, "uri", uri, "trace", trace)
+	app.apiError(w, r, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+}
+
+// apiClientError is the JSON equivalent of clientError, used by /api routes.
+func (app *application) apiClientError(w http.ResponseWriter, r *http.Request, status int) {
+	app.apiError(w, r, status, http.StatusText(status))
+}
+
+// apiValidationError reports the failed checks of a form as a
+// {"errors": {...}} body with a 422 status.
+func (app *application) apiValidationError(w http.ResponseWriter, r *http.Request, v validators.Validator) {
+	errs := map[string]string{}
+	for key, message := range v.Errors {
+		errs[key] = message
+	}
+
+	for _, message := range v.NonFieldErrors {
+		errs["non_field"] = message
+	}
+
+	if err := app.writeJSON(w, http.StatusUnprocessableEntity, envelope{"errors": errs}, nil); err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
+
+func (app *application) apiInvalidCredentials(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	app.apiError(w, r, http.StatusUnauthorized, "invalid or missing authentication credentials")
+}
+
+func (app *application) apiError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	env := envelope{"error": message}
+	if status >= http.StatusInternalServerError {
+		if id := requestIDFromContext(r); id != "" {
+			env["request_id"] = id
+		}
+	}
+
+	if err := app.writeJSON(w, status, env, nil); err != nil {
+		app.requestLogger(r).Error(err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
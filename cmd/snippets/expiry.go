@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/config"
+	"github.com/yousifsabah0/snippets/internal/models"
+	"github.com/yousifsabah0/snippets/internal/models/snippets"
+	"github.com/yousifsabah0/snippets/internal/validators"
+)
+
+// expiryUnit is a unit an expiry can be given in.
+type expiryUnit struct {
+	Name     string
+	Duration time.Duration
+}
+
+// expiryUnits are the units an expiry can be given in, smallest first.
+var expiryUnits = []expiryUnit{
+	{"minutes", time.Minute},
+	{"hours", time.Hour},
+	{"days", 24 * time.Hour},
+	{"weeks", 7 * 24 * time.Hour},
+	{"years", 365 * 24 * time.Hour},
+}
+
+// neverUnit is the unit of the expiry of snippets that never expire, which
+// only the policy can allow.
+const neverUnit = "never"
+
+// expiryFields choose when a snippet expires, as a number of units from
+// now. They are shared by the snippet form and the expiry form.
+type expiryFields struct {
+	ExpiresIn   int    `form:"expires_in" json:"expires_in"`
+	ExpiresUnit string `form:"expires_unit" json:"expires_unit"`
+}
+
+// defaultExpiry returns the fields for the longest expiry up to a year that
+// the policy allows, in the largest unit that gives it exactly.
+func defaultExpiry(policy config.Snippets) expiryFields {
+	d := min(policy.MaxExpiry, 365*24*time.Hour)
+
+	for i := len(expiryUnits) - 1; i > 0; i-- {
+		if unit := expiryUnits[i]; d%unit.Duration == 0 {
+			return expiryFields{ExpiresIn: int(d / unit.Duration), ExpiresUnit: unit.Name}
+		}
+	}
+
+	return expiryFields{ExpiresIn: int(d / time.Minute), ExpiresUnit: "minutes"}
+}
+
+// check validates the fields against the policy, reporting any problem
+// under the expires key.
+func (f *expiryFields) check(v *validators.Validator, policy config.Snippets) {
+	names := make([]string, 0, len(expiryUnits)+1)
+	for _, unit := range expiryUnits {
+		names = append(names, unit.Name)
+	}
+	if policy.AllowNeverExpire {
+		names = append(names, neverUnit)
+	}
+
+	if !validators.PermittedValue(f.ExpiresUnit, names...) {
+		last := len(names) - 1
+		v.AddError("expires", fmt.Sprintf("This field must equal %s, or %s", strings.Join(names[:last], ", "), names[last]))
+		return
+	}
+
+	if f.ExpiresUnit == neverUnit {
+		return
+	}
+
+	unit := f.unit()
+	v.CheckField(f.ExpiresIn >= 1, "expires", "This field must be at least 1")
+	// Compared in units, as the duration could overflow.
+	v.CheckField(f.ExpiresIn <= int(policy.MaxExpiry/unit.Duration), "expires", "This field cannot be more than "+humanDuration(policy.MaxExpiry))
+}
+
+func (f expiryFields) unit() expiryUnit {
+	for _, unit := range expiryUnits {
+		if unit.Name == f.ExpiresUnit {
+			return unit
+		}
+	}
+
+	return expiryUnit{}
+}
+
+// expires returns when a snippet expires when the fields are given at now.
+func (f expiryFields) expires(now time.Time) time.Time {
+	if f.ExpiresUnit == neverUnit {
+		return snippets.Never
+	}
+
+	return now.Add(time.Duration(f.ExpiresIn) * f.unit().Duration)
+}
+
+type expiryForm struct {
+	expiryFields
+	validators.Validator `form:"-"`
+}
+
+// snippetExpiry shows how long a snippet has left, and lets its author
+// change that.
+func (app *application) snippetExpiry(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.viewedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	app.renderExpiry(w, r, http.StatusOK, snippet, expiryForm{expiryFields: defaultExpiry(app.snippetPolicy)})
+}
+
+// snippetExpiryPost extends or shortens the life of a snippet, from now.
+func (app *application) snippetExpiryPost(w http.ResponseWriter, r *http.Request) {
+	snippet := app.contextSnippet(r)
+
+	var form expiryForm
+	if err := app.decodePostForm(r, &form); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.check(&form.Validator, app.snippetPolicy)
+
+	if !form.Valid() {
+		app.renderExpiry(w, r, http.StatusUnprocessableEntity, snippet, form)
+		return
+	}
+
+	if err := app.snippets.SetExpiry(r.Context(), snippet.ID, form.expires(time.Now())); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.session.Put(r.Context(), "flash", "Snippet expiry successfully updated")
+	http.Redirect(w, r, fmt.Sprintf("/snippets/view/%d/expiry", snippet.ID), http.StatusSeeOther)
+}
+
+func (app *application) renderExpiry(w http.ResponseWriter, r *http.Request, status int, snippet snippets.Snippet, form expiryForm) {
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.TimeLeft = snippet.TimeLeft(time.Now())
+	data.Form = form
+
+	app.render(w, r, status, "expiry.html", data)
+}
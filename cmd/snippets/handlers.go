@@ -2,11 +2,18 @@ package main
 
 import (
 	"errors"
-	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/yousifsabah0/snippets/internal/config"
+	"github.com/yousifsabah0/snippets/internal/diff"
+	"github.com/yousifsabah0/snippets/internal/highlight"
 	"github.com/yousifsabah0/snippets/internal/models"
+	"github.com/yousifsabah0/snippets/internal/models/snippets"
+	"github.com/yousifsabah0/snippets/internal/models/tokens"
+	"github.com/yousifsabah0/snippets/internal/search"
 	"github.com/yousifsabah0/snippets/internal/validators"
 )
 
@@ -15,7 +22,13 @@ func (app *application) ping(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	snippets, err := app.snippets.Latest()
+	snippets, err := app.snippets.Latest(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	tags, err := app.snippets.Tags(r.Context())
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -23,50 +36,463 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 
 	data := app.newTemplateData(r)
 	data.Snippets = snippets
+	data.TagCloud = newTagCloud(tags)
 
 	app.render(w, r, http.StatusOK, "home.html", data)
 }
 
+// snippetMine lists the snippets of the logged in user, including the
+// unlisted and private ones that the other pages leave out.
+func (app *application) snippetMine(w http.ResponseWriter, r *http.Request) {
+	snippets, err := app.snippets.ByUser(r.Context(), app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+
+	app.render(w, r, http.StatusOK, "mine.html", data)
+}
+
+type snippetListForm struct {
+	Sort                 string `form:"sort"`
+	Author               string `form:"author"`
+	Tag                  string `form:"tag"`
+	From                 string `form:"from"`
+	To                   string `form:"to"`
+	After                string `form:"after"`
+	Before               string `form:"before"`
+	validators.Validator `form:"-"`
+}
+
+// snippetList serves both the browse page and the pages of a tag, which are
+// the browse page with the tag filled in from the path.
+func (app *application) snippetList(w http.ResponseWriter, r *http.Request) {
+	var form snippetListForm
+	if err := app.formDecoder.Decode(&form, r.URL.Query()); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if tag := r.PathValue("tag"); tag != "" {
+		if !validators.Matches(tag, validators.TagRX) {
+			http.NotFound(w, r)
+			return
+		}
+
+		form.Tag = tag
+	}
+
+	opts := snippets.ListOptions{
+		Sort:   snippets.Sort(form.Sort),
+		Author: strings.TrimSpace(form.Author),
+		Tag:    strings.ToLower(strings.TrimSpace(form.Tag)),
+		After:  form.After,
+		Before: form.Before,
+	}
+
+	form.CheckField(form.Sort == "" || validators.PermittedValue(opts.Sort, snippets.Sorts...), "sort", "This field must be newest, oldest or expiring")
+	form.CheckField(opts.Tag == "" || validators.Matches(opts.Tag, validators.TagRX), "tag", "This field must be a valid tag")
+
+	var ok bool
+	opts.From, ok = validators.ParseDate(form.From)
+	form.CheckField(ok, "from", "This field must be a date like 2006-01-02")
+
+	opts.To, ok = validators.ParseDate(form.To)
+	form.CheckField(ok, "to", "This field must be a date like 2006-01-02")
+	if !opts.To.IsZero() {
+		// The range includes the whole of its last day.
+		opts.To = opts.To.AddDate(0, 0, 1)
+	}
+
+	data := app.newTemplateData(r)
+
+	if !form.Valid() {
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "browse.html", data)
+		return
+	}
+
+	page, err := app.snippets.List(r.Context(), opts)
+	if err != nil {
+		if errors.Is(err, snippets.ErrInvalidCursor) {
+			app.clientError(w, http.StatusBadRequest)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	data.Form = form
+	data.Snippets = page.Snippets
+	data.Pagination = newPagination(r, page)
+
+	app.render(w, r, http.StatusOK, "browse.html", data)
+}
+
+// searchLimit is the most results a search page shows.
+const searchLimit = 50
+
+// searchHit is a search result with the matches in it marked up.
+type searchHit struct {
+	snippets.SearchResult
+	Title     search.Fragment
+	Fragments []search.Fragment
+}
+
+func (app *application) snippetSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	tag := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("tag")))
+
+	data := app.newTemplateData(r)
+	data.Query = query
+	data.Tag = tag
+
+	if query != "" {
+		results, err := app.snippets.Search(r.Context(), query, tag, searchLimit)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		terms := search.Terms(query)
+		for _, result := range results {
+			data.SearchHits = append(data.SearchHits, searchHit{
+				SearchResult: result,
+				Title:        search.Mark(result.Title, terms),
+				Fragments:    search.Highlight(result.Content, terms, 3),
+			})
+		}
+	}
+
+	app.render(w, r, http.StatusOK, "search.html", data)
+}
+
+// viewedSnippet finds the snippet a request to /snippets/view/{id} or
+// /s/{slug} is for. When there is none the user may read, it responds with
+// not found and returns false. Snippets that may not be read are not found,
+// rather than forbidden, so that their IDs do not give away that they exist.
+func (app *application) viewedSnippet(w http.ResponseWriter, r *http.Request) (snippets.Snippet, bool) {
+	userID := app.authenticatedUserID(r)
+
+	var (
+		snippet snippets.Snippet
+		err     error
+	)
+
+	if slug := r.PathValue("slug"); slug != "" {
+		snippet, err = app.snippets.GetBySlug(r.Context(), slug)
+		if err == nil && !snippet.VisibleBySlugTo(userID) {
+			err = models.ErrNoRecord
+		}
+	} else {
+		id, convErr := strconv.Atoi(r.PathValue("id"))
+		if convErr != nil || id < 1 {
+			http.NotFound(w, r)
+			return snippets.Snippet{}, false
+		}
+
+		snippet, err = app.snippets.Get(r.Context(), id)
+		if err == nil && !snippet.VisibleTo(userID) {
+			err = models.ErrNoRecord
+		}
+	}
+
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return snippets.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+// snippetView shows a snippet by its ID or, which is how unlisted snippets
+// are shared, by its slug.
 func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.viewedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if !app.unlocked(r, snippet) {
+		app.renderUnlock(w, r, http.StatusOK, snippet, unlockForm{})
+		return
+	}
+
+	// Link previews and crawlers follow links, so views that count against
+	// a limit have to be asked for with snippetViewPost.
+	if snippet.MaxViews > 0 {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+
+		app.render(w, r, http.StatusOK, "reveal.html", data)
+		return
+	}
+
+	app.renderSnippet(w, r, snippet)
+}
+
+// snippetViewPost shows a snippet with a view limit, counting the view.
+func (app *application) snippetViewPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.viewedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	// The view is not counted until the snippet is unlocked.
+	if !app.unlocked(r, snippet) {
+		http.Redirect(w, r, snippetURL(snippet), http.StatusSeeOther)
+		return
+	}
+
+	// Someone else may have used up the last view in the meantime.
+	snippet, err := app.snippets.View(r.Context(), snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.renderSnippet(w, r, snippet)
+}
+
+func (app *application) renderSnippet(w http.ResponseWriter, r *http.Request, snippet snippets.Snippet) {
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+
+	// Encrypted snippets are decrypted, and shown, by the browser.
+	if !snippet.Encrypted {
+		highlighted, err := highlight.HTML(snippet.Content, snippet.Language)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		data.Highlighted = highlighted
+	}
+
+	app.metrics.snippetsViewed.Inc()
+
+	app.render(w, r, http.StatusOK, "view.html", data)
+}
+
+// highlightCSS serves the stylesheet for highlighted snippets, which is
+// generated rather than kept with the other static files.
+func (app *application) highlightCSS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+
+	if err := highlight.WriteCSS(w); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil || id < 1 {
-		app.logger.Error(err.Error())
-		app.clientError(w, http.StatusNotFound)
+		http.NotFound(w, r)
 		return
 	}
 
-	snippet, err := app.snippets.Get(id)
+	snippet, err := app.snippets.Get(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			http.NotFound(w, r)
-			return
 		} else {
 			app.serverError(w, r, err)
 		}
 		return
 	}
 
+	if !snippet.HistoryVisibleTo(app.authenticatedUserID(r)) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !app.unlocked(r, snippet) {
+		http.Redirect(w, r, snippetURL(snippet), http.StatusSeeOther)
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(r.Context(), id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Revisions = revisions
+
+	app.render(w, r, http.StatusOK, "history.html", data)
+}
 
+func (app *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	snippet, err := app.snippets.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if !snippet.HistoryVisibleTo(app.authenticatedUserID(r)) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !app.unlocked(r, snippet) {
+		http.Redirect(w, r, snippetURL(snippet), http.StatusSeeOther)
+		return
+	}
+
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil || from < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	to, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil || to < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var revisions [2]snippets.Revision
+	for i, n := range []int{from, to} {
+		revisions[i], err = app.snippets.Revision(r.Context(), id, n)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				http.NotFound(w, r)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+	}
+
+	data := app.newTemplateData(r)
 	data.Snippet = snippet
+	data.DiffFrom = revisions[0]
+	data.DiffTo = revisions[1]
+	data.Hunks = diff.Unified(revisions[0].Content, revisions[1].Content, 3)
 
-	app.render(w, r, http.StatusOK, "view.html", data)
+	app.render(w, r, http.StatusOK, "diff.html", data)
 }
 
 func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = snippetCreateForm{
-		Expires: 365,
+		Language:     highlight.Auto,
+		Visibility:   snippets.Public,
+		expiryFields: defaultExpiry(app.snippetPolicy),
 	}
 
 	app.render(w, r, http.StatusOK, "create.html", data)
 }
 
+// maxViews is the highest view limit a snippet can have.
+const maxViews = 100
+
+// maxContent is the most characters a snippet can have, which also bounds
+// the work of diffing two of its revisions.
+const maxContent = 100_000
+
 type snippetCreateForm struct {
-	Title                string `form:"title"`
-	Content              string `form:"content"`
-	Expires              int    `form:"expires"`
-	validators.Validator `form:"-"`
+	Title          string              `form:"title" json:"title"`
+	Content        string              `form:"content" json:"content"`
+	Language       string              `form:"language" json:"language"`
+	Visibility     snippets.Visibility `form:"visibility" json:"visibility"`
+	Encrypted      bool                `form:"encrypted" json:"encrypted"`
+	MaxViews       int                 `form:"max_views" json:"max_views"`
+	Password       string              `form:"password" json:"password"`
+	RemovePassword bool                `form:"remove_password" json:"-"`
+	Tags           tagList             `form:"tags" json:"tags"`
+	expiryFields
+	// Expires is the expiry in days that clients of the API from before
+	// expiry units send.
+	Expires int `form:"-" json:"expires"`
+	// HasPassword tells the edit page to offer removing the password.
+	HasPassword          bool `form:"-" json:"-"`
+	validators.Validator `form:"-" json:"-"`
+}
+
+// validate runs the checks shared by snippet creation and editing.
+func (form *snippetCreateForm) validate() {
+	form.CheckField(validators.NotBlank(form.Title), "title", "This field is required")
+	form.CheckField(validators.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validators.NotBlank(form.Content), "content", "This field is required")
+	form.CheckField(validators.MaxChars(form.Content, maxContent), "content", "This field cannot be more than 100000 characters long")
+
+	if form.Encrypted {
+		// The language of encrypted content can be neither detected nor
+		// highlighted.
+		form.Language = ""
+		form.CheckField(snippets.ValidCiphertext(form.Content), "content", "This field must be base64-encoded ciphertext")
+	} else {
+		if form.Language == highlight.Auto {
+			form.Language = highlight.Detect(form.Content)
+		}
+		form.CheckField(highlight.Supported(form.Language), "language", "This field must be a supported language")
+	}
+
+	form.Tags = form.Tags.normalize()
+	validateTags(&form.Validator, form.Tags)
+
+	// Clients of the API from before visibilities keep making public
+	// snippets.
+	if form.Visibility == "" {
+		form.Visibility = snippets.Public
+	}
+	form.CheckField(validators.PermittedValue(form.Visibility, snippets.Visibilities...), "visibility", "This field must equal public, unlisted, or private")
+	form.CheckField(validators.Between(form.MaxViews, 0, maxViews), "max_views", "This field must be between 0 and 100")
+
+	if form.Password != "" {
+		form.CheckField(validators.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+		// bcrypt refuses longer passwords.
+		form.CheckField(len(form.Password) <= 72, "password", "This field cannot be more than 72 bytes long")
+	}
+}
+
+// validateExpiry checks when a new snippet expires. Editing a snippet keeps
+// its expiry, which its author changes on the expiry page instead.
+func (form *snippetCreateForm) validateExpiry(policy config.Snippets) {
+	if form.ExpiresUnit == "" && form.Expires != 0 {
+		form.ExpiresIn, form.ExpiresUnit = form.Expires, "days"
+	}
+
+	form.check(&form.Validator, policy)
+}
+
+// draft returns the snippet the form describes.
+func (form *snippetCreateForm) draft() snippets.Draft {
+	return snippets.Draft{
+		Title:          form.Title,
+		Content:        form.Content,
+		Language:       form.Language,
+		Tags:           form.Tags,
+		Visibility:     form.Visibility,
+		Encrypted:      form.Encrypted,
+		MaxViews:       form.MaxViews,
+		Password:       form.Password,
+		RemovePassword: form.RemovePassword,
+	}
 }
 
 func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request) {
@@ -76,12 +502,12 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	form.CheckField(validators.NotBlank(form.Title), "title", "This field is required")
-	form.CheckField(validators.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validators.NotBlank(form.Content), "content", "This field is required")
-	form.CheckField(validators.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equals 1, 7, or 365")
+	form.validate()
+	form.validateExpiry(app.snippetPolicy)
 
 	if !form.Valid() {
+		form.Password = ""
+
 		data := app.newTemplateData(r)
 		data.Form = form
 
@@ -89,18 +515,106 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	draft := form.draft()
+	draft.Expires = form.expires(time.Now())
+
+	id, err := app.snippets.Insert(r.Context(), app.authenticatedUserID(r), draft)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.metrics.snippetsCreated.Inc()
+
+	// Unlisted snippets are shown at their slug, so that the address bar
+	// has the link to share.
+	snippet, err := app.snippets.Get(r.Context(), id)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
+
 	app.session.Put(r.Context(), "flash", "A new snippet successfully created")
-	http.Redirect(w, r, fmt.Sprintf("/snippets/view/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, snippetURL(snippet), http.StatusSeeOther)
 
 	// w.WriteHeader(http.StatusCreated)
 	// w.Write([]byte("Wassssssssup. creating a snippet"))
 }
 
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet := app.contextSnippet(r)
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = snippetCreateForm{
+		Title:      snippet.Title,
+		Content:    snippet.Content,
+		Language:   snippet.Language,
+		Tags:       snippet.Tags,
+		Visibility: snippet.Visibility,
+		Encrypted:  snippet.Encrypted,
+		MaxViews:   snippet.MaxViews,
+		// The password itself is never shown again.
+		HasPassword: snippet.Protected(),
+	}
+
+	app.render(w, r, http.StatusOK, "edit.html", data)
+}
+
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet := app.contextSnippet(r)
+
+	var form snippetCreateForm
+	if err := app.decodePostForm(r, &form); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.validate()
+
+	if !form.Valid() {
+		form.Password = ""
+		form.HasPassword = snippet.Protected()
+
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+
+		app.render(w, r, http.StatusUnprocessableEntity, "edit.html", data)
+		return
+	}
+
+	if err := app.snippets.Update(r.Context(), snippet.ID, form.draft()); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The slug changes when the snippet becomes unlisted.
+	snippet, err := app.snippets.Get(r.Context(), snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.session.Put(r.Context(), "flash", "Snippet successfully updated")
+	http.Redirect(w, r, snippetURL(snippet), http.StatusSeeOther)
+}
+
+func (app *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	snippet := app.contextSnippet(r)
+
+	if err := app.snippets.Delete(r.Context(), snippet.ID); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.session.Put(r.Context(), "flash", "Snippet successfully deleted")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
 type signupForm struct {
 	Name                 string `form:"name"`
 	Email                string `form:"email"`
@@ -136,7 +650,7 @@ func (app *application) signup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := app.users.Insert(form.Name, form.Email, form.Password); err != nil {
+	if err := app.users.Insert(r.Context(), form.Name, form.Email, form.Password); err != nil {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.AddError("email", "email address is already used")
 
@@ -144,6 +658,7 @@ func (app *application) signup(w http.ResponseWriter, r *http.Request) {
 			data.Form = form
 
 			app.render(w, r, http.StatusUnprocessableEntity, "signup.html", data)
+			return
 		}
 		app.serverError(w, r, err)
 		return
@@ -185,7 +700,7 @@ func (app *application) login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := app.users.Authenticate(form.Email, form.Password)
+	id, err := app.users.Authenticate(r.Context(), form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
 			form.AddNonFieldError("Incorrect both")
@@ -193,7 +708,8 @@ func (app *application) login(w http.ResponseWriter, r *http.Request) {
 			data := app.newTemplateData(r)
 			data.Form = form
 
-			app.render(w, r, http.StatusUnsupportedMediaType, "login.html", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "login.html", data)
+			return
 		}
 
 		app.serverError(w, r, err)
@@ -220,3 +736,86 @@ func (app *application) logout(w http.ResponseWriter, r *http.Request) {
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
+
+type tokenCreateForm struct {
+	Name                 string   `form:"name"`
+	Scopes               []string `form:"scopes"`
+	Expires              int      `form:"expires"`
+	validators.Validator `form:"-"`
+}
+
+func (app *application) tokenList(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = tokenCreateForm{
+		Scopes:  []string{tokens.ScopeRead},
+		Expires: 30,
+	}
+
+	app.renderTokens(w, r, http.StatusOK, data)
+}
+
+func (app *application) tokenCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form tokenCreateForm
+	if err := app.decodePostForm(r, &form); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validators.NotBlank(form.Name), "name", "This field is required")
+	form.CheckField(validators.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+	form.CheckField(len(form.Scopes) > 0, "scopes", "Pick at least one scope")
+	form.CheckField(validators.PermittedValues(form.Scopes, tokens.ScopeRead, tokens.ScopeWrite), "scopes", "This field must be read or write")
+	form.CheckField(validators.PermittedValue(form.Expires, 7, 30, 90, 365), "expires", "This field must equals 7, 30, 90, or 365")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+
+		app.renderTokens(w, r, http.StatusUnprocessableEntity, data)
+		return
+	}
+
+	token, err := app.tokens.New(r.Context(), app.authenticatedUserID(r), form.Name, form.Scopes, time.Duration(form.Expires)*24*time.Hour)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The plaintext is handed to the next page load through the session and
+	// popped from it there, so it can only ever be displayed once.
+	app.session.Put(r.Context(), "newToken", token.Plaintext)
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
+
+func (app *application) tokenRevokePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err := app.tokens.Revoke(r.Context(), app.authenticatedUserID(r), id); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.session.Put(r.Context(), "flash", "Token successfully revoked")
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
+
+func (app *application) renderTokens(w http.ResponseWriter, r *http.Request, status int, data templateData) {
+	tokens, err := app.tokens.ForUser(r.Context(), app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.Tokens = tokens
+	data.NewToken = app.session.PopString(r.Context(), "newToken")
+
+	app.render(w, r, status, "tokens.html", data)
+}
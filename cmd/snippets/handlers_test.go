@@ -2,18 +2,27 @@ package main
 
 import (
 	"bytes"
+	"fmt"
+	"html"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/yousifsabah0/snippets/internal/assert"
+	"github.com/yousifsabah0/snippets/internal/models/snippets"
+	"github.com/yousifsabah0/snippets/internal/models/tokens"
 )
 
 func TestPing(t *testing.T) {
 	app := &application{
-		logger: slog.New(slog.DiscardHandler),
+		logger:  slog.New(slog.DiscardHandler),
+		metrics: newMetrics(nil),
 	}
 
 	ts := httptest.NewTLSServer(app.routes())
@@ -36,6 +45,796 @@ func TestPing(t *testing.T) {
 	assert.Equal(t, string(body), "pong")
 }
 
+func TestSnippetView(t *testing.T) {
+	app, _ := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	if err := app.users.Insert(t.Context(), "Alice", "alice@example.com", "pa55word"); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := app.snippets.Insert(t.Context(), 1, snippets.Draft{Title: "An old silent pond", Content: "An old silent pond...", Visibility: snippets.Public, Expires: time.Now().AddDate(0, 0, 7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid ID",
+			urlPath:  fmt.Sprintf("/snippets/view/%d", id),
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond...",
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippets/view/2",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Negative ID",
+			urlPath:  "/snippets/view/-1",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "String ID",
+			urlPath:  "/snippets/view/foo",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, _, body := ts.get(t, test.urlPath)
+
+			assert.Equal(t, code, test.wantCode)
+			if test.wantBody != "" {
+				assert.Equal(t, strings.Contains(body, test.wantBody), true)
+			}
+		})
+	}
+}
+
+func TestSnippetCreate(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	for _, name := range []string{"Alice", "Bob"} {
+		if err := app.users.Insert(t.Context(), name, strings.ToLower(name)+"@example.com", "pa55word"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Visitor", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		for _, path := range []string{"/snippets/create", "/account/snippets"} {
+			code, header, _ := ts.get(t, path)
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, header.Get("Location"), "/users/login")
+		}
+	})
+
+	t.Run("Author", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.login(t, "bob@example.com", "pa55word")
+
+		_, _, body := ts.get(t, "/snippets/create")
+
+		form := url.Values{}
+		form.Add("title", "Runbook")
+		form.Add("content", "step one")
+		form.Add("expires_in", "1")
+		form.Add("expires_unit", "weeks")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, header, _ := ts.postForm(t, "/snippets/create", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		var id int
+		if _, err := fmt.Sscanf(header.Get("Location"), "/snippets/view/%d", &id); err != nil {
+			t.Fatal(err)
+		}
+
+		snippet, err := app.snippets.Get(t.Context(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, snippet.UserID, 2)
+
+		_, _, body = ts.get(t, header.Get("Location"))
+		assert.StringContains(t, body, "By Bob")
+
+		code, _, body = ts.get(t, "/account/snippets")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Runbook")
+	})
+}
+
+func TestSnippetEditOwnership(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	for _, email := range []string{"alice@example.com", "bob@example.com"} {
+		if err := app.users.Insert(t.Context(), "User", email, "pa55word"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	id, err := app.snippets.Insert(t.Context(), 1, snippets.Draft{Title: "Runbook", Content: "step one", Visibility: snippets.Public, Expires: time.Now().AddDate(0, 0, 7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	editPath := fmt.Sprintf("/snippets/edit/%d", id)
+
+	t.Run("Other user", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.login(t, "bob@example.com", "pa55word")
+
+		code, _, _ := ts.get(t, editPath)
+		assert.Equal(t, code, http.StatusForbidden)
+	})
+
+	t.Run("Owner", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.login(t, "alice@example.com", "pa55word")
+
+		code, _, body := ts.get(t, editPath)
+		assert.Equal(t, code, http.StatusOK)
+
+		form := url.Values{}
+		form.Add("title", "Runbook")
+		form.Add("content", "")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, _, _ = ts.postForm(t, editPath, form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+		form.Set("content", "step one\nstep two")
+
+		code, _, _ = ts.postForm(t, editPath, form)
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		code, _, body = ts.get(t, fmt.Sprintf("/snippets/view/%d/diff?from=1&to=2", id))
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, strings.Contains(body, `<div class="diff-insert">&#43;step two</div>`), true)
+
+		// Editing keeps the expiry the snippet was created with.
+		snippet, err := app.snippets.Get(t.Context(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, snippet.TimeLeft(time.Now()) < 7*24*time.Hour, true)
+		assert.Equal(t, snippet.TimeLeft(time.Now()) > 6*24*time.Hour, true)
+	})
+}
+
+func TestSnippetDelete(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	for _, email := range []string{"alice@example.com", "bob@example.com"} {
+		if err := app.users.Insert(t.Context(), "User", email, "pa55word"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	id, err := app.snippets.Insert(t.Context(), 1, snippets.Draft{Title: "Leaked secret", Content: "hunter2", Visibility: snippets.Public, Expires: time.Now().AddDate(0, 0, 7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deletePath := fmt.Sprintf("/snippets/delete/%d", id)
+	viewPath := fmt.Sprintf("/snippets/view/%d", id)
+
+	t.Run("Visitor", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		_, _, body := ts.get(t, "/users/login")
+
+		code, header, _ := ts.postForm(t, deletePath, url.Values{"csrf_token": {extractCSRFToken(t, body)}})
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/users/login")
+	})
+
+	t.Run("Other user", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.login(t, "bob@example.com", "pa55word")
+
+		_, _, body := ts.get(t, viewPath)
+
+		code, _, _ := ts.postForm(t, deletePath, url.Values{"csrf_token": {extractCSRFToken(t, body)}})
+		assert.Equal(t, code, http.StatusForbidden)
+
+		code, _, _ = ts.get(t, viewPath)
+		assert.Equal(t, code, http.StatusOK)
+	})
+
+	t.Run("Owner", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.login(t, "alice@example.com", "pa55word")
+
+		_, _, body := ts.get(t, viewPath)
+
+		code, header, _ := ts.postForm(t, deletePath, url.Values{"csrf_token": {extractCSRFToken(t, body)}})
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/")
+
+		code, _, _ = ts.get(t, viewPath)
+		assert.Equal(t, code, http.StatusNotFound)
+
+		code, _, _ = ts.postForm(t, deletePath, url.Values{"csrf_token": {extractCSRFToken(t, body)}})
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+}
+
+func TestSnippetHistory(t *testing.T) {
+	app, _ := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	if err := app.users.Insert(t.Context(), "Alice", "alice@example.com", "pa55word"); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := app.snippets.Insert(t.Context(), 1, snippets.Draft{Title: "Runbook", Content: "step one", Visibility: snippets.Public, Expires: time.Now().AddDate(0, 0, 7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, content := range []string{"step one\nstep two", "step one\nstep 2"} {
+		if err := app.snippets.Update(t.Context(), id, snippets.Draft{Title: "Runbook", Content: content, Visibility: snippets.Public}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, _, body := ts.get(t, fmt.Sprintf("/snippets/view/%d/history", id))
+	assert.Equal(t, code, http.StatusOK)
+	for _, number := range []string{"#1", "#2", "#3"} {
+		assert.StringContains(t, body, "<td>"+number+"</td>")
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Insertion",
+			query:    "from=1&to=2",
+			wantCode: http.StatusOK,
+			wantBody: `<div class="diff-insert">&#43;step two</div>`,
+		},
+		{
+			name:     "Change",
+			query:    "from=2&to=3",
+			wantCode: http.StatusOK,
+			wantBody: `<div class="diff-delete">-step two</div>`,
+		},
+		{
+			name:     "Identical",
+			query:    "from=3&to=3",
+			wantCode: http.StatusOK,
+			wantBody: "The content of both revisions is identical.",
+		},
+		{
+			name:     "Missing revision",
+			query:    "from=1&to=4",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid revision",
+			query:    "from=one&to=2",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, _, body := ts.get(t, fmt.Sprintf("/snippets/view/%d/diff?%s", id, test.query))
+
+			assert.Equal(t, code, test.wantCode)
+			if test.wantBody != "" {
+				assert.StringContains(t, body, test.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetList(t *testing.T) {
+	app, _ := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	for _, name := range []string{"Alice", "Bob"} {
+		if err := app.users.Insert(t.Context(), name, strings.ToLower(name)+"@example.com", "pa55word"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := range 25 {
+		if _, err := app.snippets.Insert(t.Context(), i%2+1, snippets.Draft{Title: fmt.Sprintf("Snippet %d", i+1), Content: "content", Visibility: snippets.Public, Expires: time.Now().AddDate(0, 0, 7)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, _, body := ts.get(t, "/snippets")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Snippet 25")
+	assert.Equal(t, strings.Contains(body, "Snippet 5<"), false)
+	assert.Equal(t, strings.Contains(body, `rel="prev"`), false)
+
+	next := nextPageRX.FindStringSubmatch(body)
+	if next == nil {
+		t.Fatal("no link to the next page")
+	}
+
+	code, _, body = ts.get(t, html.UnescapeString(next[1]))
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Snippet 5<")
+	assert.StringContains(t, body, `rel="prev"`)
+	assert.Equal(t, strings.Contains(body, `rel="next"`), false)
+
+	_, _, body = ts.get(t, "/snippets?author=Bob&sort=oldest")
+	assert.Equal(t, strings.Contains(body, "Snippet 1<"), false)
+	assert.StringContains(t, body, "Snippet 2<")
+
+	// Filters and sorting are kept in the page links.
+	_, _, body = ts.get(t, "/snippets?sort=oldest")
+	assert.StringContains(t, body, `href="/snippets?after=`)
+	assert.StringContains(t, body, `sort=oldest" rel="next"`)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Unknown sort", "/snippets?sort=random", http.StatusUnprocessableEntity},
+		{"Malformed date", "/snippets?from=yesterday", http.StatusUnprocessableEntity},
+		{"Malformed cursor", "/snippets?after=!!", http.StatusBadRequest},
+		{"Date range", "/snippets?from=2000-01-01&to=2000-12-31", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, _, _ := ts.get(t, test.urlPath)
+			assert.Equal(t, code, test.wantCode)
+		})
+	}
+}
+
+func TestSnippetSearch(t *testing.T) {
+	app, _ := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	if err := app.users.Insert(t.Context(), "Alice", "alice@example.com", "pa55word"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, title := range []string{"An old silent pond", "Over the wintry forest"} {
+		if _, err := app.snippets.Insert(t.Context(), 1, snippets.Draft{Title: title, Content: title + " <b>content</b>", Visibility: snippets.Public, Expires: time.Now().AddDate(0, 0, 7)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, _, body := ts.get(t, "/search?q=pond")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `An old silent <mark>pond</mark>`)
+	// The content is escaped around the highlighting.
+	assert.StringContains(t, body, `<mark>pond</mark> &lt;b&gt;content&lt;/b&gt;`)
+	assert.Equal(t, strings.Contains(body, "wintry"), false)
+
+	_, _, body = ts.get(t, "/search?q=tadpole")
+	assert.StringContains(t, body, `No snippets match "tadpole".`)
+
+	code, _, body = ts.get(t, "/search")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, strings.Contains(body, "No snippets match"), false)
+}
+
+func TestSnippetTags(t *testing.T) {
+	app, _ := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	if err := app.users.Insert(t.Context(), "Alice", "alice@example.com", "pa55word"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := app.snippets.Insert(t.Context(), 1, snippets.Draft{Title: "Untagged", Content: "content", Visibility: snippets.Public, Expires: time.Now().AddDate(0, 0, 7)}); err != nil {
+		t.Fatal(err)
+	}
+
+	ts.login(t, "alice@example.com", "pa55word")
+
+	_, _, body := ts.get(t, "/snippets/create")
+
+	form := url.Values{}
+	form.Add("title", "Restart pods")
+	form.Add("content", "kubectl rollout restart")
+	form.Add("tags", "a, b, c, d, e, f")
+	form.Add("expires_in", "1")
+	form.Add("expires_unit", "weeks")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, body := ts.postForm(t, "/snippets/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field cannot have more than 5 tags")
+	assert.StringContains(t, body, `value="a, b, c, d, e, f"`)
+
+	form.Set("tags", "K8s oncall,k8s")
+
+	code, header, _ := ts.postForm(t, "/snippets/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.get(t, header.Get("Location"))
+	assert.StringContains(t, body, `<a class="tag" href="/tags/k8s">k8s</a><a class="tag" href="/tags/oncall">oncall</a>`)
+
+	code, _, body = ts.get(t, "/tags/k8s")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Restart pods")
+	assert.Equal(t, strings.Contains(body, "Untagged"), false)
+
+	code, _, _ = ts.get(t, "/tags/Not%20a%20tag")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	_, _, body = ts.get(t, "/tags")
+	assert.StringContains(t, body, `href="/tags/oncall">oncall <small>1</small></a>`)
+
+	_, _, body = ts.get(t, "/search?q=restart&tag=bash")
+	assert.Equal(t, strings.Contains(body, "Restart pods"), false)
+}
+
+func TestSnippetVisibility(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	for _, email := range []string{"alice@example.com", "bob@example.com"} {
+		if err := app.users.Insert(t.Context(), "User", email, "pa55word"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	alice := newTestServer(t, app.routes())
+	defer alice.Close()
+	alice.login(t, "alice@example.com", "pa55word")
+
+	_, _, body := alice.get(t, "/snippets/create")
+
+	form := url.Values{}
+	form.Add("title", "Shared by link")
+	form.Add("content", "content")
+	form.Add("visibility", "unlisted")
+	form.Add("expires_in", "1")
+	form.Add("expires_unit", "weeks")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, header, _ := alice.postForm(t, "/snippets/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, strings.HasPrefix(header.Get("Location"), "/s/"), true)
+	unlistedURL := header.Get("Location")
+
+	form.Set("title", "Kept to myself")
+	form.Set("visibility", "private")
+
+	code, _, _ = alice.postForm(t, "/snippets/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	form.Set("visibility", "secret")
+
+	code, _, body = alice.postForm(t, "/snippets/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field must equal public, unlisted, or private")
+
+	private, err := app.snippets.Get(t.Context(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, private.Visibility, snippets.Private)
+
+	bob := newTestServer(t, app.routes())
+	defer bob.Close()
+	bob.login(t, "bob@example.com", "pa55word")
+
+	anonymous := newTestServer(t, app.routes())
+	defer anonymous.Close()
+
+	tests := []struct {
+		name     string
+		ts       *testServer
+		urlPath  string
+		wantCode int
+	}{
+		{"Unlisted by slug", anonymous, unlistedURL, http.StatusOK},
+		{"Unlisted by ID", anonymous, "/snippets/view/1", http.StatusNotFound},
+		{"Unlisted history", anonymous, "/snippets/view/1/history", http.StatusNotFound},
+		{"Unlisted by ID to its owner", alice, "/snippets/view/1", http.StatusOK},
+		{"Private by ID", bob, "/snippets/view/2", http.StatusNotFound},
+		{"Private by slug", bob, "/s/" + private.Slug, http.StatusNotFound},
+		{"Private diff", bob, "/snippets/view/2/diff?from=1&to=1", http.StatusNotFound},
+		{"Private edit", bob, "/snippets/edit/2", http.StatusNotFound},
+		{"Private to its owner", alice, "/snippets/view/2", http.StatusOK},
+		{"Unknown slug", anonymous, "/s/nope", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, _, _ := test.ts.get(t, test.urlPath)
+			assert.Equal(t, code, test.wantCode)
+		})
+	}
+
+	_, _, body = anonymous.get(t, "/")
+	assert.Equal(t, strings.Contains(body, "Shared by link"), false)
+	assert.Equal(t, strings.Contains(body, "Kept to myself"), false)
+}
+
+func TestSnippetViewLimit(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	if err := app.users.Insert(t.Context(), "Alice", "alice@example.com", "pa55word"); err != nil {
+		t.Fatal(err)
+	}
+
+	alice := newTestServer(t, app.routes())
+	defer alice.Close()
+	alice.login(t, "alice@example.com", "pa55word")
+
+	_, _, body := alice.get(t, "/snippets/create")
+
+	form := url.Values{}
+	form.Add("title", "Database password")
+	form.Add("content", "hunter2")
+	form.Add("max_views", "101")
+	form.Add("expires_in", "1")
+	form.Add("expires_unit", "days")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, body := alice.postForm(t, "/snippets/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field must be between 0 and 100")
+
+	form.Set("max_views", "1")
+
+	code, header, _ := alice.postForm(t, "/snippets/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	viewPath := header.Get("Location")
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Following the link, as a link preview would, does not use the view.
+	for range 2 {
+		code, _, body = ts.get(t, viewPath)
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "This snippet will be deleted once you view it.")
+		assert.Equal(t, strings.Contains(body, "hunter2"), false)
+	}
+
+	code, _, _ = ts.postForm(t, viewPath, url.Values{})
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	reveal := url.Values{"csrf_token": {extractCSRFToken(t, body)}}
+
+	code, _, body = ts.postForm(t, viewPath, reveal)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "hunter2")
+	assert.StringContains(t, body, "This snippet has now been deleted.")
+
+	code, _, _ = ts.postForm(t, viewPath, reveal)
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = ts.get(t, viewPath)
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetPassword(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	if err := app.users.Insert(t.Context(), "Alice", "alice@example.com", "pa55word"); err != nil {
+		t.Fatal(err)
+	}
+
+	alice := newTestServer(t, app.routes())
+	defer alice.Close()
+	alice.login(t, "alice@example.com", "pa55word")
+
+	_, _, body := alice.get(t, "/snippets/create")
+
+	form := url.Values{}
+	form.Add("title", "Database password")
+	form.Add("content", "hunter2")
+	form.Add("password", "s3cr3t")
+	form.Add("expires_in", "1")
+	form.Add("expires_unit", "days")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, body := alice.postForm(t, "/snippets/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field must be at least 8 characters long")
+	assert.Equal(t, strings.Contains(body, "s3cr3t"), false)
+
+	form.Set("password", "open sesame")
+
+	code, header, _ := alice.postForm(t, "/snippets/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	viewPath := header.Get("Location")
+
+	// The author needs no password.
+	_, _, body = alice.get(t, viewPath)
+	assert.StringContains(t, body, "hunter2")
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body = ts.get(t, viewPath)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "This snippet is protected by a password.")
+	assert.Equal(t, strings.Contains(body, "hunter2"), false)
+
+	code, header, _ = ts.get(t, viewPath+"/history")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), viewPath)
+
+	code, _, _ = ts.postForm(t, viewPath+"/unlock", url.Values{"password": {"open sesame"}})
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	unlock := url.Values{"csrf_token": {extractCSRFToken(t, body)}, "password": {"close sesame"}}
+
+	code, _, body = ts.postForm(t, viewPath+"/unlock", unlock)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Wrong password")
+	assert.Equal(t, strings.Contains(body, "hunter2"), false)
+
+	unlock.Set("password", "open sesame")
+
+	code, header, _ = ts.postForm(t, viewPath+"/unlock", unlock)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), viewPath)
+
+	// The session remembers the unlock.
+	code, _, body = ts.get(t, viewPath)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "hunter2")
+
+	code, _, _ = ts.get(t, viewPath+"/history")
+	assert.Equal(t, code, http.StatusOK)
+
+	// Another visitor from the same address gets the rest of its wrong
+	// passwords, then not even the right one is checked.
+	other := newTestServer(t, app.routes())
+	defer other.Close()
+
+	_, _, body = other.get(t, viewPath)
+	unlock = url.Values{"csrf_token": {extractCSRFToken(t, body)}, "password": {"close sesame"}}
+
+	for range unlockIPLimit - 1 {
+		code, _, _ = other.postForm(t, viewPath+"/unlock", unlock)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+	}
+
+	unlock.Set("password", "open sesame")
+
+	code, header, body = other.postForm(t, viewPath+"/unlock", unlock)
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.Equal(t, header.Get("Retry-After"), "900")
+	assert.StringContains(t, body, "Too many wrong passwords, try again later")
+	assert.Equal(t, strings.Contains(body, "hunter2"), false)
+}
+
+func TestSnippetEncrypted(t *testing.T) {
+	app, _ := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	if err := app.users.Insert(t.Context(), "Alice", "alice@example.com", "pa55word"); err != nil {
+		t.Fatal(err)
+	}
+
+	ts.login(t, "alice@example.com", "pa55word")
+
+	_, _, body := ts.get(t, "/snippets/create")
+
+	// Without JavaScript, the content is sent as it is.
+	form := url.Values{}
+	form.Add("title", "Launch codes")
+	form.Add("content", "package main\n\nfunc main() {}\n")
+	form.Add("encrypted", "true")
+	form.Add("expires_in", "1")
+	form.Add("expires_unit", "weeks")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, body := ts.postForm(t, "/snippets/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field must be base64-encoded ciphertext")
+
+	ciphertext := "Cr6eXPFIenZ22xO9dqkX7Lf9j1ryorys1VpsT3ZhXoNDs5W8SOZWUg=="
+	form.Set("content", ciphertext)
+
+	code, header, _ := ts.postForm(t, "/snippets/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	viewPath := header.Get("Location")
+
+	code, _, body = ts.get(t, viewPath)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `data-ciphertext="`+ciphertext+`"`)
+	assert.StringContains(t, body, "<span>Plain text</span>")
+	assert.Equal(t, strings.Contains(body, `class="chroma"`), false)
+	assert.Equal(t, strings.Contains(body, "/history"), false)
+
+	code, _, _ = ts.get(t, viewPath+"/history")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, body = ts.get(t, "/search?q=launch")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, strings.Contains(body, viewPath), false)
+}
+
+func TestSnippetHighlight(t *testing.T) {
+	app, _ := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	if err := app.users.Insert(t.Context(), "Alice", "alice@example.com", "pa55word"); err != nil {
+		t.Fatal(err)
+	}
+
+	ts.login(t, "alice@example.com", "pa55word")
+
+	_, _, body := ts.get(t, "/snippets/create")
+	assert.StringContains(t, body, `<option value="auto" selected>Auto-detect</option>`)
+
+	form := url.Values{}
+	form.Add("title", "Hello")
+	form.Add("content", "package main\n\nfunc main() {}\n")
+	form.Add("language", "Brainfuck")
+	form.Add("expires_in", "1")
+	form.Add("expires_unit", "weeks")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, body := ts.postForm(t, "/snippets/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field must be a supported language")
+
+	form.Set("language", "auto")
+
+	code, header, _ := ts.postForm(t, "/snippets/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.get(t, header.Get("Location"))
+	assert.StringContains(t, body, `<span>Go</span>`)
+	assert.StringContains(t, body, `<span class="ln" id="L3"><a class="lnlinks" href="#L3">3</a></span>`)
+	assert.StringContains(t, body, `<span class="kd">func</span>`)
+	// The content security policy forbids inline styles.
+	assert.Equal(t, strings.Contains(body, "style="), false)
+
+	code, header, body = ts.get(t, "/static/css/highlight.css")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "text/css; charset=utf-8")
+	assert.StringContains(t, body, ".chroma .kd")
+
+	code, _, _ = ts.get(t, "/static/css/main.css")
+	assert.Equal(t, code, http.StatusOK)
+}
+
+var nextPageRX = regexp.MustCompile(`<a href="([^"]+)" rel="next">`)
+
 /**
  *
 	rr := httptest.NewRecorder()
@@ -60,3 +859,193 @@ func TestPing(t *testing.T) {
 	body = bytes.TrimSpace(body)
 	assert.Equal(t, string(body), "pong")
 */
+
+func TestSnippetExpiry(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	for _, email := range []string{"alice@example.com", "bob@example.com"} {
+		if err := app.users.Insert(t.Context(), "User", email, "pa55word"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	id, err := app.snippets.Insert(t.Context(), 1, snippets.Draft{Title: "Runbook", Content: "step one", Visibility: snippets.Public, Expires: time.Now().AddDate(0, 0, 7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expiryPath := fmt.Sprintf("/snippets/view/%d/expiry", id)
+
+	t.Run("Visitor", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		code, _, body := ts.get(t, expiryPath)
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "6 days 23 hours left")
+		assert.Equal(t, strings.Contains(body, "Change expiry"), false)
+	})
+
+	t.Run("Other user", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.login(t, "bob@example.com", "pa55word")
+
+		_, _, body := ts.get(t, expiryPath)
+
+		form := url.Values{}
+		form.Add("expires_in", "1")
+		form.Add("expires_unit", "minutes")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, _, _ := ts.postForm(t, expiryPath, form)
+		assert.Equal(t, code, http.StatusForbidden)
+	})
+
+	t.Run("Owner", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.login(t, "alice@example.com", "pa55word")
+
+		code, _, body := ts.get(t, expiryPath)
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Change expiry")
+
+		form := url.Values{}
+		form.Add("expires_in", "2")
+		form.Add("expires_unit", "years")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, _, body = ts.postForm(t, expiryPath, form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "This field cannot be more than 1 year")
+
+		form.Set("expires_unit", "never")
+
+		code, _, body = ts.postForm(t, expiryPath, form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "This field must equal minutes, hours, days, weeks, or years")
+
+		form.Set("expires_in", "90")
+		form.Set("expires_unit", "minutes")
+
+		code, header, _ := ts.postForm(t, expiryPath, form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), expiryPath)
+
+		_, _, body = ts.get(t, expiryPath)
+		assert.StringContains(t, body, "1 hour 29 minutes left")
+
+		app.snippetPolicy.AllowNeverExpire = true
+		defer func() { app.snippetPolicy.AllowNeverExpire = false }()
+
+		form.Set("expires_unit", "never")
+
+		code, _, _ = ts.postForm(t, expiryPath, form)
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		_, _, body = ts.get(t, expiryPath)
+		assert.StringContains(t, body, "This snippet never expires.")
+
+		_, _, body = ts.get(t, fmt.Sprintf("/snippets/view/%d", id))
+		assert.StringContains(t, body, "Expires: Never")
+	})
+}
+
+var newTokenRX = regexp.MustCompile(`<code>(snp_[^<]+)</code>`)
+
+func TestTokens(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	for _, name := range []string{"Alice", "Bob"} {
+		if err := app.users.Insert(t.Context(), name, strings.ToLower(name)+"@example.com", "pa55word"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	alice := newTestServer(t, app.routes())
+	defer alice.Close()
+	alice.login(t, "alice@example.com", "pa55word")
+
+	code, _, body := alice.get(t, "/account/tokens")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "You don't have any API tokens yet.")
+
+	form := url.Values{}
+	form.Add("name", "ci")
+	form.Add("expires", "30")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, body = alice.postForm(t, "/account/tokens", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Pick at least one scope")
+
+	form.Add("scopes", "read")
+
+	code, header, _ := alice.postForm(t, "/account/tokens", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/account/tokens")
+
+	_, _, body = alice.get(t, "/account/tokens")
+	matches := newTokenRX.FindStringSubmatch(body)
+	if len(matches) < 2 {
+		t.Fatal("no new token found in body")
+	}
+	plaintext := matches[1]
+
+	// The token is only ever shown once.
+	_, _, body = alice.get(t, "/account/tokens")
+	assert.Equal(t, strings.Contains(body, plaintext), false)
+
+	api := func(method, token string) int {
+		req, err := http.NewRequest(method, alice.URL+"/api/v1/snippets", strings.NewReader(`{"title": "Deploy", "content": "make deploy", "expires": 7}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		code, _, _ := alice.do(t, req)
+		return code
+	}
+
+	assert.Equal(t, api(http.MethodGet, plaintext), http.StatusOK)
+	// Its scopes are only read.
+	assert.Equal(t, api(http.MethodPost, plaintext), http.StatusForbidden)
+
+	expired, err := app.tokens.New(t.Context(), 1, "old", []string{tokens.ScopeRead}, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, api(http.MethodGet, expired.Plaintext), http.StatusUnauthorized)
+
+	mine, err := app.tokens.ForUser(t.Context(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var id int
+	for _, token := range mine {
+		if token.Name == "ci" {
+			id = token.ID
+		}
+	}
+	revokePath := fmt.Sprintf("/account/tokens/%d/revoke", id)
+
+	bob := newTestServer(t, app.routes())
+	defer bob.Close()
+	bob.login(t, "bob@example.com", "pa55word")
+
+	_, _, body = bob.get(t, "/account/tokens")
+
+	code, _, _ = bob.postForm(t, revokePath, url.Values{"csrf_token": {extractCSRFToken(t, body)}})
+	assert.Equal(t, code, http.StatusNotFound)
+	assert.Equal(t, api(http.MethodGet, plaintext), http.StatusOK)
+
+	_, _, body = alice.get(t, "/account/tokens")
+
+	code, _, _ = alice.postForm(t, revokePath, url.Values{"csrf_token": {extractCSRFToken(t, body)}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, api(http.MethodGet, plaintext), http.StatusUnauthorized)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// readinessTimeout bounds every readiness check, so that a hanging database
+// fails the probe instead of stalling it.
+const readinessTimeout = 2 * time.Second
+
+type checkResult struct {
+	Status   string `json:"status"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// healthz reports that the process is up and serving requests.
+func (app *application) healthz(w http.ResponseWriter, r *http.Request) {
+	if err := app.writeJSON(w, http.StatusOK, envelope{"status": "ok"}, nil); err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
+
+// readyz reports whether the instance should receive traffic: its
+// dependencies must respond and it must not be shutting down. Anyone can
+// reach it, so it only names the checks and whether they pass.
+func (app *application) readyz(w http.ResponseWriter, r *http.Request) {
+	results, healthy := app.checkReadiness(r)
+
+	statuses := make(map[string]string, len(results))
+	for name, result := range results {
+		statuses[name] = result.Status
+	}
+
+	app.writeReadiness(w, r, healthy, statuses)
+}
+
+// readyzDetails is readyz for the admin listener, which also reports how
+// long every check took and why failing ones failed. Errors can carry
+// database hosts and driver messages that anonymous clients should not see.
+func (app *application) readyzDetails(w http.ResponseWriter, r *http.Request) {
+	results, healthy := app.checkReadiness(r)
+
+	app.writeReadiness(w, r, healthy, results)
+}
+
+// checkReadiness runs every readiness check concurrently, logging the
+// results when one fails.
+func (app *application) checkReadiness(r *http.Request) (map[string]checkResult, bool) {
+	checks := map[string]func(context.Context) error{
+		"templates":     app.checkTemplates,
+		"session_store": app.checkSessionStore,
+		"shutdown":      app.checkShutdown,
+	}
+
+	if app.db != nil {
+		checks["database"] = app.db.PingContext
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results = map[string]checkResult{}
+		healthy = true
+	)
+
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check(ctx)
+
+			result := checkResult{Status: "ok", Duration: time.Since(start).String()}
+			if err != nil {
+				result.Status = "failing"
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[name] = result
+			healthy = healthy && err == nil
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	if !healthy {
+		app.requestLogger(r).Warn("readiness check failing", "checks", results)
+	}
+
+	return results, healthy
+}
+
+func (app *application) writeReadiness(w http.ResponseWriter, r *http.Request, healthy bool, checks any) {
+	status, code := "ok", http.StatusOK
+	if !healthy {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+
+	if err := app.writeJSON(w, code, envelope{"status": status, "checks": checks}, nil); err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
+
+func (app *application) checkTemplates(context.Context) error {
+	for _, page := range []string{"home.html", "view.html", "error.html"} {
+		if app.templateCace[page] == nil {
+			return errors.New("template " + page + " is missing")
+		}
+	}
+
+	return nil
+}
+
+// checkSessionStore looks up a token that cannot exist, which exercises the
+// store's backend without writing to it.
+func (app *application) checkSessionStore(ctx context.Context) error {
+	const probe = "readiness-probe"
+
+	if s, ok := app.session.Store.(interface {
+		FindCtx(context.Context, string) ([]byte, bool, error)
+	}); ok {
+		_, _, err := s.FindCtx(ctx, probe)
+		return err
+	}
+
+	_, _, err := app.session.Store.Find(probe)
+
+	return err
+}
+
+func (app *application) checkShutdown(context.Context) error {
+	if app.shuttingDown.Load() {
+		return errors.New("shutting down")
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/yousifsabah0/snippets/internal/assert"
+)
+
+func TestHealthz(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/healthz")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, `{"status":"ok"}`)
+}
+
+func TestReadyz(t *testing.T) {
+	closedDB, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedDB.Close()
+
+	tests := []struct {
+		name     string
+		setup    func(app *application)
+		wantCode int
+		failing  string
+	}{
+		{
+			name:     "Ready",
+			setup:    func(app *application) {},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Shutting down",
+			setup:    func(app *application) { app.shuttingDown.Store(true) },
+			wantCode: http.StatusServiceUnavailable,
+			failing:  "shutdown",
+		},
+		{
+			name:     "Database unreachable",
+			setup:    func(app *application) { app.db = closedDB },
+			wantCode: http.StatusServiceUnavailable,
+			failing:  "database",
+		},
+		{
+			name:     "Template missing",
+			setup:    func(app *application) { delete(app.templateCace, "home.html") },
+			wantCode: http.StatusServiceUnavailable,
+			failing:  "templates",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newTestApplication(t)
+			tt.setup(app)
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			code, _, body := ts.get(t, "/readyz")
+			assert.Equal(t, code, tt.wantCode)
+
+			// The public endpoint only names the checks.
+			var resp struct {
+				Status string
+				Checks map[string]string
+			}
+			if err := json.Unmarshal([]byte(body), &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			for name, status := range resp.Checks {
+				want := "ok"
+				if name == tt.failing {
+					want = "failing"
+				}
+				assert.Equal(t, status, want)
+			}
+
+			admin := newTestServer(t, app.adminRoutes("", ""))
+			defer admin.Close()
+
+			code, _, body = admin.get(t, "/readyz")
+			assert.Equal(t, code, tt.wantCode)
+
+			var details struct {
+				Status string
+				Checks map[string]checkResult
+			}
+			if err := json.Unmarshal([]byte(body), &details); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, len(details.Checks), len(resp.Checks))
+
+			if tt.failing != "" {
+				assert.Equal(t, resp.Status, "unavailable")
+				assert.Equal(t, details.Status, "unavailable")
+				if details.Checks[tt.failing].Error == "" {
+					t.Errorf("check %q has no error", tt.failing)
+				}
+			}
+		})
+	}
+}
@@ -2,13 +2,17 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/go-playground/form/v4"
 	"github.com/justinas/nosurf"
+	"github.com/yousifsabah0/snippets/internal/models/snippets"
 )
 
 func (app *application) render(w http.ResponseWriter, r *http.Request, status int, page string, data templateData) {
@@ -21,14 +25,47 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, status in
 
 	buf := new(bytes.Buffer)
 
-	if err := ts.ExecuteTemplate(w, "index", data); err != nil {
+	_, span := tracer.Start(r.Context(), "render "+page)
+	start := time.Now()
+	err := ts.ExecuteTemplate(buf, "index", data)
+	app.metrics.renderDuration.WithLabelValues(page).Observe(time.Since(start).Seconds())
+	span.End()
+
+	if err != nil {
 		app.serverError(w, r, err)
+		return
 	}
 
 	w.WriteHeader(status)
 	buf.WriteTo(w)
 }
 
+// requestLogger returns the logger that requestID attached to r, or the
+// application logger for requests that did not pass through it.
+func (app *application) requestLogger(r *http.Request) *slog.Logger {
+	if logger, ok := r.Context().Value(loggerContextKey).(*slog.Logger); ok {
+		return logger
+	}
+
+	return app.logger
+}
+
+func requestIDFromContext(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey).(string)
+	return id
+}
+
+// newFormDecoder returns the decoder for HTML forms, taught about the custom
+// field types the forms use.
+func newFormDecoder() *form.Decoder {
+	decoder := form.NewDecoder()
+	decoder.RegisterCustomTypeFunc(func(values []string) (any, error) {
+		return parseTagList(values[0]), nil
+	}, tagList{})
+
+	return decoder
+}
+
 func (app *application) decodePostForm(r *http.Request, v any) error {
 	if err := r.ParseForm(); err != nil {
 		return err
@@ -47,11 +84,49 @@ func (app *application) decodePostForm(r *http.Request, v any) error {
 }
 
 func (app *application) newTemplateData(r *http.Request) templateData {
-	return templateData{
+	data := templateData{
 		CurrentYear:     time.Now().Year(),
 		Flash:           app.session.PopString(r.Context(), "flash"),
 		IsAuthenticated: app.isAuthenticated(r),
 		CSRFToken:       nosurf.Token(r),
+		// The snippet and expiry forms offer what the policy allows.
+		AllowNeverExpire: app.snippetPolicy.AllowNeverExpire,
+		MaxExpiry:        app.snippetPolicy.MaxExpiry,
+	}
+
+	if data.IsAuthenticated {
+		data.AuthenticatedUserID = app.authenticatedUserID(r)
+	}
+
+	return data
+}
+
+// pagination holds the links to the neighbouring pages of a listing, empty
+// when there is no such page.
+type pagination struct {
+	Prev string
+	Next string
+}
+
+// newPagination links to the pages around page, keeping the rest of the
+// request's query string so that filters and sorting carry over.
+func newPagination(r *http.Request, page snippets.Page) pagination {
+	link := func(key, cursor string) string {
+		if cursor == "" {
+			return ""
+		}
+
+		query := r.URL.Query()
+		query.Del("after")
+		query.Del("before")
+		query.Set(key, cursor)
+
+		return r.URL.Path + "?" + query.Encode()
+	}
+
+	return pagination{
+		Prev: link("before", page.Prev),
+		Next: link("after", page.Next),
 	}
 }
 
@@ -63,3 +138,73 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 
 	return isAuthenticated
 }
+
+// contextSnippet returns the snippet that requiredOwnership stored in the
+// request context.
+func (app *application) contextSnippet(r *http.Request) snippets.Snippet {
+	snippet, ok := r.Context().Value(snippetContextKey).(snippets.Snippet)
+	if !ok {
+		panic("missing snippet value in request context")
+	}
+
+	return snippet
+}
+
+// snippetURL returns the link to a snippet, which for unlisted snippets is
+// by their slug.
+func snippetURL(s snippets.Snippet) string {
+	if s.Visibility == snippets.Unlisted {
+		return "/s/" + s.Slug
+	}
+
+	return fmt.Sprintf("/snippets/view/%d", s.ID)
+}
+
+type envelope map[string]any
+
+func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(js, '\n'))
+
+	return nil
+}
+
+// readJSON decodes a single JSON value from the request body into dst,
+// rejecting unknown fields and bodies larger than 1MB.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return err
+	}
+
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+// authenticatedUserID returns the ID of the user making the request, whether
+// they were authenticated by session or by API credentials, or 0.
+func (app *application) authenticatedUserID(r *http.Request) int {
+	id, ok := r.Context().Value(authIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+
+	return id
+}
@@ -1,107 +1,295 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
+	"errors"
 	"flag"
+	"fmt"
 	"html/template"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	"github.com/alexedwards/scs/mysqlstore"
 	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
 	"github.com/go-playground/form/v4"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/yousifsabah0/snippets/internal/config"
+	"github.com/yousifsabah0/snippets/internal/models/memory"
 	"github.com/yousifsabah0/snippets/internal/models/snippets"
+	"github.com/yousifsabah0/snippets/internal/models/tokens"
 	"github.com/yousifsabah0/snippets/internal/models/users"
+	"golang.org/x/crypto/acme"
 )
 
+var sameSiteModes = map[string]http.SameSite{
+	"lax":    http.SameSiteLaxMode,
+	"strict": http.SameSiteStrictMode,
+	"none":   http.SameSiteNoneMode,
+}
+
 type application struct {
 	logger       *slog.Logger
-	snippets     *snippets.SnippetModel
-	users        *users.UserModel
+	snippets     snippets.SnippetStore
+	users        users.UserStore
+	tokens       tokens.TokenStore
 	templateCace map[string]*template.Template
 	formDecoder  *form.Decoder
 	session      *scs.SessionManager
+	metrics      *metrics
+	// snippetPolicy is how long snippets may be kept.
+	snippetPolicy config.Snippets
+
+	// unlockBySnippet and unlockByIP limit the wrong passwords tried for
+	// protected snippets.
+	unlockBySnippet *throttle
+	unlockByIP      *throttle
+
+	// db is nil when the memory store is in use.
+	db *sql.DB
+	// shuttingDown is set as soon as shutdown starts, failing readiness.
+	shuttingDown atomic.Bool
 }
 
 func main() {
-	port := flag.String("port", ":8080", "HTTP network port")
-	dsn := flag.String("dsn", "odyssey:odyssey@/snippets?parseTime=true", "Database source name")
+	cfg := config.Default()
+
+	configFile := flag.String("config", "", "TOML configuration file [SNIPPETS_CONFIG]")
+	cfg.RegisterFlags(flag.CommandLine)
 
 	flag.Parse()
 
-	logger := slog.New(slog.NewJSONHandler(os.Stdin, &slog.HandlerOptions{}))
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{}))
 
-	db, err := openDB(*dsn)
+	if *configFile == "" {
+		*configFile = os.Getenv("SNIPPETS_CONFIG")
+	}
 
-	defer func() {
-		err := db.Close()
+	if err := cfg.Load(flag.CommandLine, *configFile, os.LookupEnv); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	switch flag.Arg(0) {
+	case "":
+	case "migrate":
+		db, err := openDB(cfg.Database.Driver, cfg.Database.DSN)
 		if err != nil {
 			logger.Error(err.Error(), "error", err)
 			os.Exit(1)
 		}
-	}()
 
-	if err != nil {
+		err = runMigrate(os.Stdout, db, dbDrivers[cfg.Database.Driver].dialect, flag.Args()[1:])
+		db.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		return
+	case "config":
+		if flag.Arg(1) != "print" || flag.NArg() > 2 {
+			fmt.Fprintln(os.Stderr, "usage: config print")
+			os.Exit(2)
+		}
+
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, cfg, logger); err != nil {
 		logger.Error(err.Error(), "error", err)
+		stop()
 		os.Exit(1)
 	}
 
+	logger.Info("shutdown complete")
+}
+
+// run starts the server and blocks until it fails or ctx is cancelled. On the
+// way out it stops background workers and closes the database, so a nil error
+// means everything was shut down cleanly and within the deadline.
+func run(ctx context.Context, cfg config.Config, logger *slog.Logger) (err error) {
 	tc, err := newTemplateCaceh()
 	if err != nil {
-		logger.Error(err.Error())
-		os.Exit(1)
+		return err
 	}
 
-	formDecoder := form.NewDecoder()
+	formDecoder := newFormDecoder()
 
 	session := scs.New()
-	session.Store = mysqlstore.New(db)
-	session.Lifetime = 12 * time.Hour
-	session.Cookie.Secure = true
+	session.Lifetime = cfg.Session.Lifetime
+	session.Cookie.Name = cfg.Session.CookieName
+	session.Cookie.Domain = cfg.Session.CookieDomain
+	session.Cookie.Secure = cfg.Session.CookieSecure
+	session.Cookie.SameSite = sameSiteModes[cfg.Session.CookieSameSite]
 
 	app := &application{
-		logger:       logger,
-		snippets:     &snippets.SnippetModel{DB: db},
-		users:        &users.UserModel{DB: db},
-		templateCace: tc,
-		formDecoder:  formDecoder,
-		session:      session,
+		logger:        logger,
+		templateCace:  tc,
+		formDecoder:   formDecoder,
+		session:       session,
+		snippetPolicy: cfg.Snippets,
+
+		unlockBySnippet: newThrottle(unlockSnippetLimit, unlockWindow),
+		unlockByIP:      newThrottle(unlockIPLimit, unlockWindow),
+	}
+
+	// Tracing is set up first so that the database driver picks up the
+	// tracer provider, and shut down last so that no spans are lost.
+	shutdownTracing, err := setupTracing(ctx, cfg.Tracing)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if flushErr := shutdownTracing(flushCtx); flushErr != nil {
+			err = errors.Join(err, flushErr)
+			return
+		}
+
+		if cfg.Tracing.Endpoint != "" {
+			logger.Info("flushed traces")
+		}
+	}()
+
+	var db *sql.DB
+
+	switch cfg.Store {
+	case "memory":
+		logger.Warn("using the in-memory store, nothing will be persisted")
+
+		mem := memory.NewStore()
+		app.snippets = &memory.SnippetModel{Store: mem, Cost: cfg.Auth.BcryptCost}
+		app.users = &memory.UserModel{Store: mem, Cost: cfg.Auth.BcryptCost}
+		app.tokens = &memory.TokenModel{Store: mem}
+		session.Store = memstore.New()
+	case "sql":
+		db, err = openDB(cfg.Database.Driver, cfg.Database.DSN)
+		if err != nil {
+			return err
+		}
+
+		// Deferred before the session store is created, so that the
+		// store's cleanup goroutine is stopped before the database
+		// goes away.
+		defer func() {
+			if closeErr := db.Close(); closeErr != nil {
+				err = errors.Join(err, closeErr)
+				return
+			}
+
+			logger.Info("closed database connection pool")
+		}()
+
+		dialect := dbDrivers[cfg.Database.Driver].dialect
+
+		if cfg.Database.Migrate {
+			if err := app.migrateUp(db, dialect); err != nil {
+				return err
+			}
+		}
+
+		app.db = db
+		app.snippets = &snippets.SnippetModel{DB: db, Dialect: dialect, Cost: cfg.Auth.BcryptCost}
+		app.users = &users.UserModel{DB: db, Dialect: dialect, Cost: cfg.Auth.BcryptCost}
+		app.tokens = &tokens.TokenModel{DB: db, Dialect: dialect}
+		session.Store = newSessionStore(cfg.Database.Driver, db)
+	default:
+		return fmt.Errorf("unknown store %q", cfg.Store)
 	}
 
+	app.metrics = newMetrics(db)
+	app.instrumentSessionStore()
+
+	defer app.stopSessionCleanup()
+
 	tlsConfig := &tls.Config{
 		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
 	}
 
+	// Plain HTTP is only ever redirected, apart from ACME challenges.
+	httpHandler := redirectToHTTPS(cfg.Addr)
+
+	switch cfg.TLS.Mode {
+	case "acme":
+		m, err := newACMEManager(cfg.TLS.ACME)
+		if err != nil {
+			return err
+		}
+
+		tlsConfig.GetCertificate = m.GetCertificate
+		tlsConfig.NextProtos = []string{"h2", "http/1.1", acme.ALPNProto}
+		httpHandler = m.HTTPHandler(httpHandler)
+
+		logger.Info("using ACME certificates", "domains", cfg.TLS.ACME.Domains, "directory", cfg.TLS.ACME.DirectoryURL)
+	case "file":
+		certs, err := newCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return err
+		}
+
+		tlsConfig.GetCertificate = certs.getCertificate
+		go app.reloadOnSIGHUP(ctx, certs)
+	}
+
+	errorLog := slog.NewLogLogger(logger.Handler(), slog.LevelError)
+
 	srv := &http.Server{
 		Handler:      app.routes(),
-		Addr:         *port,
-		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		Addr:         cfg.Addr,
+		ErrorLog:     errorLog,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
 		TLSConfig:    tlsConfig,
 	}
 
-	if err := srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem"); err != nil {
-		logger.Error(err.Error(), "err", err)
-		os.Exit(1)
-	}
-}
+	servers := []server{{srv, func() error { return srv.ListenAndServeTLS("", "") }}}
 
-func openDB(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
-	if err != nil {
-		return nil, err
+	if cfg.TLS.HTTPAddr != "" {
+		httpSrv := &http.Server{
+			Handler:      httpHandler,
+			Addr:         cfg.TLS.HTTPAddr,
+			ErrorLog:     errorLog,
+			IdleTimeout:  cfg.Server.IdleTimeout,
+			ReadTimeout:  cfg.Server.ReadTimeout,
+			WriteTimeout: cfg.Server.WriteTimeout,
+		}
+
+		servers = append(servers, server{httpSrv, httpSrv.ListenAndServe})
 	}
 
-	if err := db.Ping(); err != nil {
-		db.Close()
-		return nil, err
+	if cfg.Admin.Addr != "" {
+		adminSrv := &http.Server{
+			Handler:      app.adminRoutes(cfg.Admin.Username, cfg.Admin.Password),
+			Addr:         cfg.Admin.Addr,
+			ErrorLog:     errorLog,
+			IdleTimeout:  cfg.Server.IdleTimeout,
+			ReadTimeout:  cfg.Server.ReadTimeout,
+			WriteTimeout: cfg.Server.WriteTimeout,
+		}
+
+		servers = append(servers, server{adminSrv, adminSrv.ListenAndServe})
 	}
 
-	return db, nil
+	return app.serve(ctx, cfg.Server.DrainDelay, cfg.Server.ShutdownTimeout, servers...)
 }
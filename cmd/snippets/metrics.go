@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/codes"
+)
+
+type metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	responseSize    *prometheus.HistogramVec
+	sessionOps      *prometheus.HistogramVec
+	renderDuration  *prometheus.HistogramVec
+	snippetsCreated prometheus.Counter
+	snippetsViewed  prometheus.Counter
+}
+
+// newMetrics registers the application's metrics on a new registry, along
+// with the connection pool statistics of db if it is not nil.
+func newMetrics(db *sql.DB) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "snippets_http_requests_total",
+			Help: "HTTP requests handled, by route pattern and status code.",
+		}, []string{"pattern", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "snippets_http_request_duration_seconds",
+			Help:    "Time taken to handle HTTP requests, by route pattern and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"pattern", "status"}),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "snippets_http_response_size_bytes",
+			Help:    "Size of HTTP response bodies, by route pattern and status code.",
+			Buckets: prometheus.ExponentialBuckets(128, 4, 8),
+		}, []string{"pattern", "status"}),
+		sessionOps: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "snippets_session_store_operation_duration_seconds",
+			Help:    "Time taken by session store operations, by operation and result.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation", "result"}),
+		renderDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "snippets_template_render_duration_seconds",
+			Help:    "Time taken to render HTML templates, by page.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 4, 8),
+		}, []string{"page"}),
+		snippetsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "snippets_created_total",
+			Help: "Snippets created through the web interface or the API.",
+		}),
+		snippetsViewed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "snippets_viewed_total",
+			Help: "Snippets viewed through the web interface or the API.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.responseSize,
+		m.sessionOps,
+		m.renderDuration,
+		m.snippetsCreated,
+		m.snippetsViewed,
+	)
+
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, "snippets"))
+	}
+
+	return m
+}
+
+// instrument records every request, labelled by the pattern the mux matched
+// rather than the raw URI to keep the number of series bounded. No middleware
+// between it and the mux may copy the request, since the mux sets the
+// pattern on the request it is given.
+func (app *application) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := wrapResponseWriter(w)
+
+		next.ServeHTTP(rw, r)
+
+		pattern := r.Pattern
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+
+		labels := prometheus.Labels{"pattern": pattern, "status": strconv.Itoa(rw.status)}
+		app.metrics.requests.With(labels).Inc()
+		app.metrics.requestDuration.With(labels).Observe(time.Since(start).Seconds())
+		app.metrics.responseSize.With(labels).Observe(float64(rw.bytes))
+	})
+}
+
+// adminRoutes returns the handler for the admin listener. When a password is
+// configured every request has to authenticate with basic auth.
+func (app *application) adminRoutes(username, password string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", app.healthz)
+	mux.HandleFunc("GET /readyz", app.readyzDetails)
+	mux.Handle("GET /metrics", promhttp.HandlerFor(app.metrics.registry, promhttp.HandlerOpts{
+		ErrorLog: slogPromLogger{app},
+	}))
+
+	if password == "" {
+		return mux
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(pass), []byte(password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="admin", charset="UTF-8"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// slogPromLogger passes errors from promhttp on to the application logger.
+type slogPromLogger struct {
+	app *application
+}
+
+func (l slogPromLogger) Println(v ...any) {
+	l.app.logger.Error("serving metrics", "error", v)
+}
+
+// instrumentedStore times every operation of the session store it wraps,
+// and traces it as part of the request when scs passes a context along.
+type instrumentedStore struct {
+	scs.Store
+	ops *prometheus.HistogramVec
+}
+
+func (s *instrumentedStore) observe(ctx context.Context, op string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "session "+op)
+
+	return ctx, func(err error) {
+		result := "ok"
+		if err != nil {
+			result = "error"
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		span.End()
+		s.ops.WithLabelValues(op, result).Observe(time.Since(start).Seconds())
+	}
+}
+
+func (s *instrumentedStore) Find(token string) ([]byte, bool, error) {
+	return s.FindCtx(context.Background(), token)
+}
+
+func (s *instrumentedStore) FindCtx(ctx context.Context, token string) (b []byte, found bool, err error) {
+	ctx, done := s.observe(ctx, "find")
+	defer func() { done(err) }()
+
+	if cs, ok := s.Store.(scs.CtxStore); ok {
+		return cs.FindCtx(ctx, token)
+	}
+
+	return s.Store.Find(token)
+}
+
+func (s *instrumentedStore) Commit(token string, b []byte, expiry time.Time) error {
+	return s.CommitCtx(context.Background(), token, b, expiry)
+}
+
+func (s *instrumentedStore) CommitCtx(ctx context.Context, token string, b []byte, expiry time.Time) (err error) {
+	ctx, done := s.observe(ctx, "commit")
+	defer func() { done(err) }()
+
+	if cs, ok := s.Store.(scs.CtxStore); ok {
+		return cs.CommitCtx(ctx, token, b, expiry)
+	}
+
+	return s.Store.Commit(token, b, expiry)
+}
+
+func (s *instrumentedStore) Delete(token string) error {
+	return s.DeleteCtx(context.Background(), token)
+}
+
+func (s *instrumentedStore) DeleteCtx(ctx context.Context, token string) (err error) {
+	ctx, done := s.observe(ctx, "delete")
+	defer func() { done(err) }()
+
+	if cs, ok := s.Store.(scs.CtxStore); ok {
+		return cs.DeleteCtx(ctx, token)
+	}
+
+	return s.Store.Delete(token)
+}
+
+// StopCleanup stops the wrapped store's cleanup goroutine, if it has one.
+func (s *instrumentedStore) StopCleanup() {
+	if c, ok := s.Store.(interface{ StopCleanup() }); ok {
+		c.StopCleanup()
+	}
+}
+
+var _ scs.CtxStore = (*instrumentedStore)(nil)
+
+// instrumentSessionStore must be called once session.Store is set.
+func (app *application) instrumentSessionStore() {
+	app.session.Store = &instrumentedStore{Store: app.session.Store, ops: app.metrics.sessionOps}
+}
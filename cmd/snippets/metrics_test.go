@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/yousifsabah0/snippets/internal/assert"
+)
+
+func TestInstrument(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.get(t, "/ping")
+	ts.get(t, "/ping")
+	ts.get(t, "/snippets/view/1")
+	ts.get(t, "/snippets/view/2")
+	ts.get(t, "/no/such/page")
+
+	requests := func(pattern, status string) float64 {
+		return testutil.ToFloat64(app.metrics.requests.WithLabelValues(pattern, status))
+	}
+
+	assert.Equal(t, requests("GET /ping", "200"), 2)
+	// Both views are counted under the one pattern, not per URI.
+	assert.Equal(t, requests("GET /snippets/view/{id}", "404"), 2)
+	assert.Equal(t, requests("unmatched", "404"), 1)
+
+	assert.Equal(t, testutil.CollectAndCount(app.metrics.responseSize), 3)
+
+	// Loading the home page goes through the session store and renders a
+	// template.
+	ts.get(t, "/")
+	assert.Equal(t, testutil.CollectAndCount(app.metrics.renderDuration), 1)
+}
+
+func TestAdminRoutes(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		password string
+		user     string
+		pass     string
+		wantCode int
+	}{
+		{"No auth configured", "", "", "", http.StatusOK},
+		{"Missing credentials", "secret", "", "", http.StatusUnauthorized},
+		{"Wrong password", "secret", "admin", "wrong", http.StatusUnauthorized},
+		{"Valid credentials", "secret", "admin", "secret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.user != "" {
+				r.SetBasicAuth(tt.user, tt.pass)
+			}
+
+			rr := httptest.NewRecorder()
+			app.adminRoutes("admin", tt.password).ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+			if tt.wantCode == http.StatusOK {
+				assert.StringContains(t, rr.Body.String(), "snippets_created_total")
+			}
+		})
+	}
+}
@@ -2,10 +2,19 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/justinas/alice"
 	"github.com/justinas/nosurf"
+	"github.com/yousifsabah0/snippets/internal/models"
+	"github.com/yousifsabah0/snippets/internal/models/tokens"
 )
 
 func headers(next http.Handler) http.Handler {
@@ -36,10 +45,70 @@ func (app *application) panicRecovery(next http.Handler) http.Handler {
 	})
 }
 
+// requestID tags every request with an ID, taken from the X-Request-ID header
+// when a proxy in front of us already set a sensible one. The ID is echoed in
+// the response and attached to a logger stored in the request context, so
+// that everything logged while handling the request can be correlated.
+func (app *application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+
+		ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+		ctx = context.WithValue(ctx, loggerContextKey, app.logger.With("request_id", id))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// validRequestID accepts IDs of up to 128 letters, digits, dots, dashes and
+// underscores, which covers UUIDs and the formats common proxies generate
+// while keeping anything odd out of the logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// logRequest logs every request once it has been handled, along with the
+// response status, size and how long it took.
 func (app *application) logRequest(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
-		app.logger.Info("Request: ", "method", r.Method, "uri", r.URL.RequestURI(), "ip", r.RemoteAddr)
-		next.ServeHTTP(w, r)
+		start := time.Now()
+		rw := wrapResponseWriter(w)
+
+		next.ServeHTTP(rw, r)
+
+		app.requestLogger(r).Info("request",
+			"method", r.Method,
+			"uri", r.URL.RequestURI(),
+			"proto", r.Proto,
+			"ip", r.RemoteAddr,
+			"status", rw.status,
+			"bytes", rw.bytes,
+			"duration", time.Since(start).String(),
+		)
 	}
 
 	return http.HandlerFunc(fn)
@@ -58,6 +127,46 @@ func (app *application) requiredAuth(next http.Handler) http.Handler {
 	})
 }
 
+// requiredOwnership must run after requiredAuth. It loads the snippet named
+// by the {id} path value and only lets the request through when it belongs
+// to the authenticated user, storing the snippet in the request context.
+func (app *application) requiredOwnership(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil || id < 1 {
+			http.NotFound(w, r)
+			return
+		}
+
+		snippet, err := app.snippets.Get(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				http.NotFound(w, r)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+
+		userID := app.authenticatedUserID(r)
+
+		// Other users' snippets that they may not read are not found at
+		// all, as in viewedSnippet.
+		if !snippet.VisibleTo(userID) {
+			http.NotFound(w, r)
+			return
+		}
+
+		if snippet.UserID != userID {
+			app.clientError(w, http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), snippetContextKey, snippet)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func noSurf(next http.Handler) http.Handler {
 	handler := nosurf.New(next)
 	handler.SetBaseCookie(http.Cookie{
@@ -77,7 +186,7 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		exists, err := app.users.Exists(id)
+		exists, err := app.users.Exists(r.Context(), id)
 		if err != nil {
 			app.serverError(w, r, err)
 			return
@@ -85,9 +194,129 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 
 		if exists {
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, authIDContextKey, id)
 			r = r.WithContext(ctx)
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
+
+// apiAuthenticate is the /api counterpart of authenticate. API clients don't
+// carry a session cookie, so they send a personal API token with every
+// request in an "Authorization: Bearer" header.
+func (app *application) apiAuthenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scheme, plaintext, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || plaintext == "" {
+			app.apiInvalidCredentials(w, r)
+			return
+		}
+
+		token, err := app.tokens.Authenticate(r.Context(), plaintext)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				app.apiInvalidCredentials(w, r)
+			} else {
+				app.apiServerError(w, r, err)
+			}
+			return
+		}
+
+		exists, err := app.users.Exists(r.Context(), token.UserID)
+		if err != nil {
+			app.apiServerError(w, r, err)
+			return
+		}
+
+		if !exists {
+			app.apiInvalidCredentials(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+		ctx = context.WithValue(ctx, authIDContextKey, token.UserID)
+		ctx = context.WithValue(ctx, tokenContextKey, token)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (app *application) requiredAPIAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			app.apiInvalidCredentials(w, r)
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requiredScope must run after requiredAPIAuth. It rejects requests whose
+// token does not grant scope.
+func (app *application) requiredScope(scope string) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := r.Context().Value(tokenContextKey).(tokens.Token)
+			if !ok || !token.HasScope(scope) {
+				app.apiError(w, r, http.StatusForbidden, fmt.Sprintf("this token does not have the %q scope", scope))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// responseWriter records the status code and the number of body bytes
+// written through it.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+// wrapResponseWriter returns w itself if an outer middleware already wrapped
+// it, so that every middleware sees the same counts.
+func wrapResponseWriter(w http.ResponseWriter) *responseWriter {
+	if rw, ok := w.(*responseWriter); ok {
+		return rw
+	}
+
+	return &responseWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -3,10 +3,13 @@ package main
 import (
 	"bytes"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/justinas/alice"
 	"github.com/yousifsabah0/snippets/internal/assert"
 )
 
@@ -60,3 +63,76 @@ func TestHeaders(t *testing.T) {
 	body = bytes.TrimSpace(body)
 	assert.Equal(t, string(body), "Dude")
 }
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"Generated", "", false},
+		{"Honoured", "4f1c2a7e-proxy_1.2", true},
+		{"Invalid characters", "id with spaces\n", false},
+		{"Too long", strings.Repeat("a", 129), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newTestApplication(t)
+
+			var logs bytes.Buffer
+			app.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+
+			var seen string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = requestIDFromContext(r)
+				app.requestLogger(r).Info("inside handler")
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				r.Header.Set("X-Request-ID", tt.incoming)
+			}
+
+			rr := httptest.NewRecorder()
+			app.requestID(next).ServeHTTP(rr, r)
+
+			id := rr.Header().Get("X-Request-ID")
+			assert.Equal(t, seen, id)
+			assert.Equal(t, id == tt.incoming, tt.keep)
+			assert.Equal(t, validRequestID(id), true)
+			assert.StringContains(t, logs.String(), `"request_id":"`+id+`"`)
+		})
+	}
+}
+
+func TestServerErrorShowsRequestID(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	var logs bytes.Buffer
+	app.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /boom", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	h := alice.New(app.requestID, app.logRequest, app.panicRecovery).Then(mux)
+
+	r := httptest.NewRequest(http.MethodGet, "/boom", nil)
+	r.Header.Set("X-Request-ID", "req-123")
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+
+	assert.Equal(t, rr.Code, http.StatusInternalServerError)
+	assert.StringContains(t, rr.Body.String(), "<code>req-123</code>")
+
+	// Both the error and the access log line carry the ID, and the access
+	// log records the status the panic turned into.
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	assert.Equal(t, len(lines), 2)
+	assert.StringContains(t, lines[0], `"msg":"boom","request_id":"req-123"`)
+	assert.StringContains(t, lines[1], `"status":500`)
+	assert.StringContains(t, lines[1], `"request_id":"req-123"`)
+}
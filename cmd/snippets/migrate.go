@@ -0,0 +1,83 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/yousifsabah0/snippets/internal/migrations"
+	"github.com/yousifsabah0/snippets/internal/models"
+)
+
+// runMigrate implements the migrate subcommand:
+//
+//	snippets [flags] migrate up|down|status|to <version>
+func runMigrate(out io.Writer, db *sql.DB, dialect models.Dialect, args []string) error {
+	m, err := migrations.New(db, dialect)
+	if err != nil {
+		return err
+	}
+
+	m.Logf = func(format string, args ...any) {
+		fmt.Fprintf(out, format+"\n", args...)
+	}
+
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down|status|to <version>")
+	}
+
+	switch args[0] {
+	case "up":
+		return m.Up()
+	case "down":
+		return m.Down()
+	case "to":
+		if len(args) != 2 {
+			return errors.New("usage: migrate to <version>")
+		}
+
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+
+		return m.To(version)
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied {
+				applied = s.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+			}
+
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+// migrateUp applies all pending migrations, for the -migrate flag.
+func (app *application) migrateUp(db *sql.DB, dialect models.Dialect) error {
+	m, err := migrations.New(db, dialect)
+	if err != nil {
+		return err
+	}
+
+	m.Logf = func(format string, args ...any) {
+		app.logger.Info(fmt.Sprintf(format, args...))
+	}
+
+	return m.Up()
+}
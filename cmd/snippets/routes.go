@@ -4,19 +4,40 @@ import (
 	"net/http"
 
 	"github.com/justinas/alice"
+	"github.com/yousifsabah0/snippets/internal/models/tokens"
 	"github.com/yousifsabah0/snippets/web"
 )
 
 func (app *application) routes() http.Handler {
 	mux := http.NewServeMux()
 
-	middleware := alice.New(app.panicRecovery, app.logRequest, headers)
-	dynamic := alice.New(app.session.LoadAndSave, noSurf, app.authenticate)
+	// The standard middleware are cheap and are covered by the request
+	// span; the route-level ones get a span each.
+	middleware := alice.New(app.requestID, app.traceRequest, app.instrument, app.logRequest, app.panicRecovery, headers)
+	dynamic := alice.New(
+		traced("session", app.session.LoadAndSave),
+		traced("noSurf", noSurf),
+		traced("authenticate", app.authenticate),
+	)
 
 	mux.HandleFunc("GET /ping", app.ping)
+	mux.HandleFunc("GET /healthz", app.healthz)
+	mux.HandleFunc("GET /readyz", app.readyz)
 
 	mux.Handle("GET /{$}", dynamic.ThenFunc(app.home))
+	mux.Handle("GET /snippets", dynamic.ThenFunc(app.snippetList))
+	mux.Handle("GET /search", dynamic.ThenFunc(app.snippetSearch))
+	mux.Handle("GET /tags", dynamic.ThenFunc(app.tagCloud))
+	mux.Handle("GET /tags/{tag}", dynamic.ThenFunc(app.snippetList))
 	mux.Handle("GET /snippets/view/{id}", dynamic.ThenFunc(app.snippetView))
+	mux.Handle("GET /snippets/view/{id}/history", dynamic.ThenFunc(app.snippetHistory))
+	mux.Handle("GET /snippets/view/{id}/diff", dynamic.ThenFunc(app.snippetDiff))
+	mux.Handle("GET /snippets/view/{id}/expiry", dynamic.ThenFunc(app.snippetExpiry))
+	mux.Handle("POST /snippets/view/{id}", dynamic.ThenFunc(app.snippetViewPost))
+	mux.Handle("GET /s/{slug}", dynamic.ThenFunc(app.snippetView))
+	mux.Handle("POST /s/{slug}", dynamic.ThenFunc(app.snippetViewPost))
+	mux.Handle("POST /snippets/view/{id}/unlock", dynamic.ThenFunc(app.snippetUnlockPost))
+	mux.Handle("POST /s/{slug}/unlock", dynamic.ThenFunc(app.snippetUnlockPost))
 
 	mux.Handle("GET /users/signup", dynamic.ThenFunc(app.signupForm))
 	mux.Handle("POST /users/signup", dynamic.ThenFunc(app.signup))
@@ -24,13 +45,37 @@ func (app *application) routes() http.Handler {
 	mux.Handle("GET /users/login", dynamic.ThenFunc(app.loginForm))
 	mux.Handle("POST /users/login", dynamic.ThenFunc(app.login))
 
-	protected := dynamic.Append(app.requiredAuth)
+	protected := dynamic.Append(traced("requiredAuth", app.requiredAuth))
 
 	mux.Handle("GET /snippets/create", protected.ThenFunc(app.snippetCreate))
 	mux.Handle("POST /snippets/create", protected.ThenFunc(app.snippetCreatePost))
 	mux.Handle("POST /users/logout", protected.ThenFunc(app.logout))
 
-	mux.Handle("GET /static/", http.FileServerFS(web.Files))
+	mux.Handle("GET /account/snippets", protected.ThenFunc(app.snippetMine))
+	mux.Handle("GET /account/tokens", protected.ThenFunc(app.tokenList))
+	mux.Handle("POST /account/tokens", protected.ThenFunc(app.tokenCreatePost))
+	mux.Handle("POST /account/tokens/{id}/revoke", protected.ThenFunc(app.tokenRevokePost))
+
+	owner := protected.Append(traced("requiredOwnership", app.requiredOwnership))
+
+	mux.Handle("GET /snippets/edit/{id}", owner.ThenFunc(app.snippetEdit))
+	mux.Handle("POST /snippets/edit/{id}", owner.ThenFunc(app.snippetEditPost))
+	mux.Handle("POST /snippets/delete/{id}", owner.ThenFunc(app.snippetDeletePost))
+	mux.Handle("POST /snippets/view/{id}/expiry", owner.ThenFunc(app.snippetExpiryPost))
+
+	mux.Handle("GET /static/", http.StripPrefix("/static", http.FileServerFS(web.Assets())))
+	mux.HandleFunc("GET /static/css/highlight.css", app.highlightCSS)
+
+	// The JSON API is stateless: it skips the session and CSRF middleware and
+	// authenticates every request on its own.
+	api := alice.New(traced("apiAuthenticate", app.apiAuthenticate), traced("requiredAPIAuth", app.requiredAPIAuth))
+
+	read := api.Append(traced("requiredScope", app.requiredScope(tokens.ScopeRead)))
+	write := api.Append(traced("requiredScope", app.requiredScope(tokens.ScopeWrite)))
+
+	mux.Handle("GET /api/v1/snippets", read.ThenFunc(app.apiSnippetList))
+	mux.Handle("GET /api/v1/snippets/{id}", read.ThenFunc(app.apiSnippetView))
+	mux.Handle("POST /api/v1/snippets", write.ThenFunc(app.apiSnippetCreate))
 
 	return middleware.Then(mux)
 }
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// server is an http.Server along with the function that starts it, such as
+// its ListenAndServeTLS method.
+type server struct {
+	*http.Server
+	listen func() error
+}
+
+// serve starts every server and blocks until one of them fails or ctx is
+// cancelled. Either way all servers are then shut down together: readiness
+// starts failing, the servers keep serving for drainDelay so that load
+// balancers notice, or until a second SIGINT or SIGTERM, then they stop
+// accepting connections and in-flight requests get until timeout to finish.
+// If they do not, serve returns an error wrapping context.DeadlineExceeded.
+func (app *application) serve(ctx context.Context, drainDelay, timeout time.Duration, servers ...server) error {
+	serveErrs := make(chan error, len(servers))
+
+	for _, srv := range servers {
+		go func() {
+			app.logger.Info("starting server", "addr", srv.Addr)
+
+			err := srv.listen()
+			if errors.Is(err, http.ErrServerClosed) {
+				err = nil
+			}
+			serveErrs <- err
+		}()
+	}
+
+	var failed error
+	select {
+	case failed = <-serveErrs:
+		if failed == nil {
+			failed = errors.New("server stopped unexpectedly")
+		}
+	case <-ctx.Done():
+	}
+
+	app.shuttingDown.Store(true)
+
+	if failed == nil && drainDelay > 0 {
+		app.logger.Info("failing readiness before shutting down", "drain_delay", drainDelay.String())
+
+		// The first signal cancelled ctx before this was registered, so
+		// only a second one cuts the delay short.
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+		drain := time.NewTimer(drainDelay)
+		select {
+		case <-drain.C:
+		case sig := <-sigs:
+			app.logger.Info("skipping the rest of the drain delay", "signal", sig.String())
+		}
+
+		drain.Stop()
+		signal.Stop(sigs)
+	}
+
+	app.logger.Info("shutting down servers", "timeout", timeout.String())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var (
+		wg           sync.WaitGroup
+		mu           sync.Mutex
+		shutdownErrs []error
+	)
+
+	for _, srv := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				srv.Close()
+
+				mu.Lock()
+				shutdownErrs = append(shutdownErrs, fmt.Errorf("%s: in-flight requests did not finish in time: %w", srv.Addr, err))
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// Collect the result of every listen call that has not been read yet.
+	remaining := len(servers)
+	if failed != nil {
+		remaining--
+	}
+
+	for range remaining {
+		if err := <-serveErrs; err != nil {
+			shutdownErrs = append(shutdownErrs, err)
+		}
+	}
+
+	if failed == nil && len(shutdownErrs) == 0 {
+		app.logger.Info("drained in-flight requests")
+	}
+
+	return errors.Join(append([]error{failed}, shutdownErrs...)...)
+}
+
+// stopSessionCleanup stops the goroutine that some session stores run to
+// delete expired sessions.
+func (app *application) stopSessionCleanup() {
+	if s, ok := app.session.Store.(interface{ StopCleanup() }); ok {
+		s.StopCleanup()
+		app.logger.Info("stopped session store cleanup")
+	}
+}
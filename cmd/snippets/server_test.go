@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"testing"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/assert"
+)
+
+func TestServeShutdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		wait    time.Duration
+		timeout time.Duration
+		wantErr bool
+	}{
+		{
+			name:    "Drained",
+			wait:    50 * time.Millisecond,
+			timeout: 5 * time.Second,
+		},
+		{
+			name:    "Deadline exceeded",
+			wait:    5 * time.Second,
+			timeout: 50 * time.Millisecond,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newTestApplication(t)
+
+			started := make(chan struct{})
+			srv := &http.Server{
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					close(started)
+					select {
+					case <-time.After(tt.wait):
+					case <-r.Context().Done():
+					}
+					w.Write([]byte("done"))
+				}),
+			}
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			served := make(chan error, 1)
+			go func() {
+				served <- app.serve(ctx, 0, tt.timeout, server{srv, func() error { return srv.Serve(ln) }})
+			}()
+
+			requested := make(chan error, 1)
+			go func() {
+				res, err := http.Get("http://" + ln.Addr().String())
+				if err == nil {
+					res.Body.Close()
+				}
+				requested <- err
+			}()
+
+			<-started
+			cancel()
+
+			err = <-served
+			assert.Equal(t, app.shuttingDown.Load(), true)
+			assert.Equal(t, err != nil, tt.wantErr)
+			if tt.wantErr {
+				assert.Equal(t, errors.Is(err, context.DeadlineExceeded), true)
+			} else {
+				// The in-flight request must have completed.
+				assert.Equal(t, <-requested, nil)
+			}
+		})
+	}
+}
+
+func TestServeDrainInterrupted(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	// Keep the signals sent below from stopping the test binary.
+	guard := make(chan os.Signal, 1)
+	signal.Notify(guard, os.Interrupt)
+	defer signal.Stop(guard)
+
+	srv := &http.Server{}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- app.serve(ctx, time.Hour, time.Second, server{srv, func() error { return srv.Serve(ln) }})
+	}()
+
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Signal until serve is waiting for one, rather than guess when it is.
+	tick := time.NewTicker(10 * time.Millisecond)
+	defer tick.Stop()
+	deadline := time.After(5 * time.Second)
+
+	for {
+		select {
+		case err := <-served:
+			assert.Equal(t, err, nil)
+			return
+		case <-tick.C:
+			if err := self.Signal(os.Interrupt); err != nil {
+				t.Fatal(err)
+			}
+		case <-deadline:
+			t.Fatal("the drain delay was not cut short")
+		}
+	}
+}
+
+func TestServeListenError(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	// When one server fails to start, the others are shut down too.
+	other := &http.Server{}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := errors.New("address in use")
+	err = app.serve(context.Background(), 0, time.Second,
+		server{other, func() error { return other.Serve(ln) }},
+		server{&http.Server{}, func() error { return want }},
+	)
+	assert.Equal(t, errors.Is(err, want), true)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/yousifsabah0/snippets/internal/models/snippets"
+	"github.com/yousifsabah0/snippets/internal/validators"
+)
+
+const (
+	maxTags      = 5
+	maxTagLength = 30
+)
+
+// tagList is the tags of a snippet. HTML forms send it as a single field
+// separated by commas or spaces, and JSON as an array.
+type tagList []string
+
+func parseTagList(s string) tagList {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
+// String joins the tags back up for the value of a form field.
+func (l tagList) String() string {
+	return strings.Join(l, ", ")
+}
+
+// normalize lower-cases the tags and drops blanks and duplicates.
+func (l tagList) normalize() tagList {
+	var tags tagList
+	for _, tag := range l {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// validateTags checks a list of tags that has been normalized.
+func validateTags(v *validators.Validator, tags tagList) {
+	v.CheckField(validators.MaxItems(tags, maxTags), "tags", "This field cannot have more than 5 tags")
+	v.CheckField(validators.AllMaxChars(tags, maxTagLength), "tags", "Tags cannot be more than 30 characters long")
+	v.CheckField(validators.AllMatch(tags, validators.TagRX), "tags", "Tags can only contain letters, digits, and . _ + -")
+}
+
+// tagCloudEntry is a tag with a weight from 1 to 5 to size it by.
+type tagCloudEntry struct {
+	snippets.TagCount
+	Weight int
+}
+
+// newTagCloud weighs tags on a logarithmic scale of their counts, so that a
+// few very common tags do not dwarf the rest.
+func newTagCloud(counts []snippets.TagCount) []tagCloudEntry {
+	most := 1
+	for _, c := range counts {
+		most = max(most, c.Count)
+	}
+
+	cloud := make([]tagCloudEntry, len(counts))
+	for i, c := range counts {
+		weight := 1
+		if most > 1 {
+			weight += int(math.Round(4 * math.Log(float64(c.Count)) / math.Log(float64(most))))
+		}
+
+		cloud[i] = tagCloudEntry{TagCount: c, Weight: weight}
+	}
+
+	return cloud
+}
+
+func (app *application) tagCloud(w http.ResponseWriter, r *http.Request) {
+	counts, err := app.snippets.Tags(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.TagCloud = newTagCloud(counts)
+
+	app.render(w, r, http.StatusOK, "tags.html", data)
+}
@@ -1,27 +1,59 @@
 package main
 
 import (
+	"fmt"
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/yousifsabah0/snippets/internal/diff"
+	"github.com/yousifsabah0/snippets/internal/highlight"
 	"github.com/yousifsabah0/snippets/internal/models/snippets"
+	"github.com/yousifsabah0/snippets/internal/models/tokens"
 	"github.com/yousifsabah0/snippets/web"
 )
 
 type templateData struct {
-	CurrentYear     int
-	Snippet         snippets.Snippet
-	Snippets        []snippets.Snippet
-	Form            any
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string
+	CurrentYear         int
+	Snippet             snippets.Snippet
+	Highlighted         template.HTML
+	TimeLeft            time.Duration
+	Snippets            []snippets.Snippet
+	Pagination          pagination
+	Query               string
+	Tag                 string
+	TagCloud            []tagCloudEntry
+	SearchHits          []searchHit
+	Revisions           []snippets.Revision
+	DiffFrom            snippets.Revision
+	DiffTo              snippets.Revision
+	Hunks               []diff.Hunk
+	Tokens              []tokens.Token
+	NewToken            string
+	Form                any
+	Flash               string
+	IsAuthenticated     bool
+	AuthenticatedUserID int
+	CSRFToken           string
+	AllowNeverExpire    bool
+	MaxExpiry           time.Duration
+	RequestID           string
 }
 
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":     humanDate,
+	"humanExpiry":   humanExpiry,
+	"humanDuration": humanDuration,
+	"expiryUnits":   func() []expiryUnit { return expiryUnits },
+	"diffClass":     diffClass,
+	"sub":           func(a, b int) int { return a - b },
+	"hasValue":      slices.Contains[[]string],
+	"languages":     func() []string { return highlight.Languages },
+	"snippetURL":    snippetURL,
+	"visibilities":  func() []snippets.Visibility { return snippets.Visibilities },
 }
 
 func humanDate(t time.Time) string {
@@ -32,6 +64,69 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// humanExpiry formats when a snippet expires, which may be never.
+func humanExpiry(t time.Time) string {
+	if !t.Before(snippets.Never) {
+		return "Never"
+	}
+
+	return humanDate(t)
+}
+
+// humanDuration formats d in its largest unit, and the next one down if
+// that is not zero, such as "3 days 4 hours". It rounds down to minutes.
+func humanDuration(d time.Duration) string {
+	units := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"year", 365 * 24 * time.Hour},
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+	}
+
+	var parts []string
+	for _, unit := range units {
+		n := d / unit.d
+		if n == 0 {
+			if len(parts) > 0 {
+				break
+			}
+			continue
+		}
+
+		part := fmt.Sprintf("%d %s", n, unit.name)
+		if n > 1 {
+			part += "s"
+		}
+		parts = append(parts, part)
+
+		if len(parts) == 2 {
+			break
+		}
+		d -= n * unit.d
+	}
+
+	if len(parts) == 0 {
+		return "less than a minute"
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// diffClass returns the CSS class used to colour a line of a diff.
+func diffClass(op diff.Op) string {
+	switch op {
+	case diff.Insert:
+		return "diff-insert"
+	case diff.Delete:
+		return "diff-delete"
+	default:
+		return "diff-equal"
+	}
+}
+
 func newTemplateCaceh() (map[string]*template.Template, error) {
 	cache := map[string]*template.Template{}
 	pages, err := fs.Glob(web.Files, "app/pages/*.html")
@@ -43,8 +138,7 @@ func newTemplateCaceh() (map[string]*template.Template, error) {
 		name := filepath.Base(page)
 		patterns := []string{
 			"app/index.html",
-			"app/partials/navbar.html",
-			"app/partials/footer.html",
+			"app/partials/*.html",
 			page,
 		}
 
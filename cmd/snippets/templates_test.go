@@ -37,3 +37,43 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestHumanDuration(t *testing.T) {
+	tests := []struct {
+		name string
+		d    time.Duration
+		want string
+	}{
+		{
+			name: "Seconds",
+			d:    30 * time.Second,
+			want: "less than a minute",
+		},
+		{
+			name: "Minute",
+			d:    time.Minute + 30*time.Second,
+			want: "1 minute",
+		},
+		{
+			name: "Hours and minutes",
+			d:    2*time.Hour + 5*time.Minute,
+			want: "2 hours 5 minutes",
+		},
+		{
+			name: "Skipped unit",
+			d:    24*time.Hour + 5*time.Minute,
+			want: "1 day",
+		},
+		{
+			name: "Years and days",
+			d:    2*365*24*time.Hour + 3*24*time.Hour + time.Hour,
+			want: "2 years 3 days",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, humanDuration(test.d), test.want)
+		})
+	}
+}
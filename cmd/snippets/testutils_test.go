@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+	"github.com/yousifsabah0/snippets/internal/config"
+	"github.com/yousifsabah0/snippets/internal/models/memory"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestApplication returns an application backed by the in-memory store,
+// along with that store so tests can seed it.
+func newTestApplication(t *testing.T) (*application, *memory.Store) {
+	tc, err := newTemplateCaceh()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := scs.New()
+	session.Store = memstore.New()
+	session.Lifetime = 12 * time.Hour
+	session.Cookie.Secure = true
+
+	store := memory.NewStore()
+
+	app := &application{
+		logger:        slog.New(slog.DiscardHandler),
+		snippets:      &memory.SnippetModel{Store: store, Cost: bcrypt.MinCost},
+		users:         &memory.UserModel{Store: store, Cost: bcrypt.MinCost},
+		tokens:        &memory.TokenModel{Store: store},
+		templateCace:  tc,
+		formDecoder:   newFormDecoder(),
+		session:       session,
+		metrics:       newMetrics(nil),
+		snippetPolicy: config.Default().Snippets,
+
+		unlockBySnippet: newThrottle(unlockSnippetLimit, unlockWindow),
+		unlockByIP:      newThrottle(unlockIPLimit, unlockWindow),
+	}
+
+	return app, store
+}
+
+type testServer struct {
+	*httptest.Server
+}
+
+func newTestServer(t *testing.T, h http.Handler) *testServer {
+	ts := httptest.NewTLSServer(h)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts.Client().Jar = jar
+
+	// Don't follow redirects, so tests can check the redirect itself.
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &testServer{ts}
+}
+
+func (ts *testServer) do(t *testing.T, req *http.Request) (int, http.Header, string) {
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(bytes.TrimSpace(body))
+}
+
+func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, string) {
+	req, err := http.NewRequest(http.MethodGet, ts.URL+urlPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ts.do(t, req)
+}
+
+func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (int, http.Header, string) {
+	req, err := http.NewRequest(http.MethodPost, ts.URL+urlPath, strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// Browsers send an Origin header with form posts, which nosurf checks.
+	req.Header.Set("Origin", ts.URL)
+
+	return ts.do(t, req)
+}
+
+// login signs the test server's client in, fetching a CSRF token first.
+func (ts *testServer) login(t *testing.T, email, password string) {
+	_, _, body := ts.get(t, "/users/login")
+
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", password)
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/users/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login as %s: got status %d", email, code)
+	}
+}
+
+var csrfTokenRX = regexp.MustCompile(`<input type="hidden" name="csrf_token" value="(.+)" />`)
+
+func extractCSRFToken(t *testing.T, body string) string {
+	matches := csrfTokenRX.FindStringSubmatch(body)
+	if len(matches) < 2 {
+		t.Fatal("no csrf token found in body")
+	}
+
+	return html.UnescapeString(matches[1])
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// throttleSweepSize is how many keys a throttle holds before it starts
+// dropping those whose window has passed.
+const throttleSweepSize = 1024
+
+// throttle allows each key limit attempts in a fixed window of time. An
+// attempt is taken before it is made, so that concurrent attempts cannot get
+// past the limit, and handed back if it should not count.
+type throttle struct {
+	limit  int
+	window time.Duration
+	now    func() time.Time
+
+	mu       sync.Mutex
+	attempts map[string]throttleWindow
+}
+
+type throttleWindow struct {
+	start time.Time
+	count int
+}
+
+func newThrottle(limit int, window time.Duration) *throttle {
+	return &throttle{
+		limit:    limit,
+		window:   window,
+		now:      time.Now,
+		attempts: make(map[string]throttleWindow),
+	}
+}
+
+// take counts an attempt for key, returning false without counting it when
+// key has no attempts left in the current window.
+func (t *throttle) take(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+
+	w, ok := t.attempts[key]
+	if !ok || now.Sub(w.start) >= t.window {
+		if len(t.attempts) >= throttleSweepSize {
+			t.sweep(now)
+		}
+
+		w = throttleWindow{start: now}
+	}
+
+	if w.count >= t.limit {
+		return false
+	}
+
+	w.count++
+	t.attempts[key] = w
+
+	return true
+}
+
+// refund hands back an attempt taken for key.
+func (t *throttle) refund(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w, ok := t.attempts[key]
+	if !ok || w.count == 0 {
+		return
+	}
+
+	w.count--
+	t.attempts[key] = w
+}
+
+// sweep drops the keys whose window has passed. It must be called with the
+// lock held.
+func (t *throttle) sweep(now time.Time) {
+	for key, w := range t.attempts {
+		if now.Sub(w.start) >= t.window {
+			delete(t.attempts, key)
+		}
+	}
+}
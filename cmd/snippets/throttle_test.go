@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/assert"
+)
+
+func TestThrottle(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	th := newThrottle(2, time.Minute)
+	th.now = func() time.Time { return now }
+
+	assert.Equal(t, th.take("a"), true)
+	assert.Equal(t, th.take("a"), true)
+	assert.Equal(t, th.take("a"), false)
+
+	// Keys are throttled on their own.
+	assert.Equal(t, th.take("b"), true)
+
+	// A refunded attempt can be taken again.
+	th.refund("a")
+	assert.Equal(t, th.take("a"), true)
+	assert.Equal(t, th.take("a"), false)
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, th.take("a"), true)
+}
+
+func TestThrottleSweep(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	th := newThrottle(1, time.Minute)
+	th.now = func() time.Time { return now }
+
+	for i := range throttleSweepSize {
+		th.take(strconv.Itoa(i))
+	}
+
+	// Once the window has passed, a new key sweeps away the old ones.
+	now = now.Add(time.Minute)
+	th.take("new")
+	assert.Equal(t, len(th.attempts), 1)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/assert"
+)
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		name      string
+		httpsAddr string
+		host      string
+		target    string
+		want      string
+	}{
+		{
+			name:      "Default port",
+			httpsAddr: ":443",
+			host:      "example.com",
+			target:    "/snippets/view/1?x=y",
+			want:      "https://example.com/snippets/view/1?x=y",
+		},
+		{
+			name:      "Custom port",
+			httpsAddr: ":8080",
+			host:      "example.com:8000",
+			target:    "/",
+			want:      "https://example.com:8080/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			r.Host = tt.host
+
+			rr := httptest.NewRecorder()
+			redirectToHTTPS(tt.httpsAddr).ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, http.StatusMovedPermanently)
+			assert.Equal(t, rr.Header().Get("Location"), tt.want)
+		})
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	writeTestCert(t, certFile, keyFile, 1)
+
+	r, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial := func() int64 {
+		t.Helper()
+
+		cert, err := r.getCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return leaf.SerialNumber.Int64()
+	}
+
+	assert.Equal(t, serial(), 1)
+
+	writeTestCert(t, certFile, keyFile, 2)
+	if err := r.reload(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, serial(), 2)
+
+	// A broken key pair is rejected and the current certificate kept.
+	if err := os.WriteFile(keyFile, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reload(); err == nil {
+		t.Error("reloading a broken key pair succeeded")
+	}
+	assert.Equal(t, serial(), 2)
+}
+
+func writeTestCert(t *testing.T, certFile, keyFile string, serial int64) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/justinas/alice"
+	"github.com/yousifsabah0/snippets/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the application's spans. It is bound to whichever provider
+// is installed globally, which stays a no-op unless tracing is configured.
+var tracer = otel.Tracer("github.com/yousifsabah0/snippets")
+
+// setupTracing installs a tracer provider exporting to the configured OTLP
+// endpoint, along with the W3C trace context propagator. The returned
+// function flushes any buffered spans; it must be called on shutdown.
+func setupTracing(ctx context.Context, cfg config.Tracing) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.Endpoint+"/v1/traces"))
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName))),
+	)
+
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
+
+// traceRequest starts the server span of every request, continuing the trace
+// of the caller if it sent a traceparent header. The trace ID is added to
+// the request logger so that log lines can be found from a trace and back.
+func (app *application) traceRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		ctx, span := tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("client.address", r.RemoteAddr),
+				attribute.String("request.id", requestIDFromContext(r)),
+			),
+		)
+		defer span.End()
+
+		if sc := span.SpanContext(); sc.IsValid() {
+			ctx = context.WithValue(ctx, loggerContextKey, app.requestLogger(r).With("trace_id", sc.TraceID().String()))
+		}
+
+		rw := wrapResponseWriter(w)
+		r = r.WithContext(ctx)
+
+		next.ServeHTTP(rw, r)
+
+		// The mux has set the pattern on r by now, which makes a far
+		// better span name than the raw path.
+		if r.Pattern != "" {
+			span.SetName(r.Pattern)
+			span.SetAttributes(attribute.String("http.route", r.Pattern))
+		}
+
+		span.SetAttributes(attribute.Int("http.response.status_code", rw.status))
+		if rw.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rw.status))
+		}
+	})
+}
+
+type middlewareSpan struct {
+	span   trace.Span
+	parent trace.Span
+}
+
+// traced wraps a middleware in a span of its own, which ends as soon as the
+// middleware hands over to the next handler. That way the span only covers
+// the middleware's own work, like loading the session, rather than the
+// whole rest of the request.
+func traced(name string, mw alice.Constructor) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		resume := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if ms, ok := ctx.Value(middlewareSpanContextKey).(middlewareSpan); ok {
+				ms.span.End()
+				ctx = trace.ContextWithSpan(context.WithValue(ctx, middlewareSpanContextKey, nil), ms.parent)
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+
+		h := mw(resume)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			parent := trace.SpanFromContext(r.Context())
+
+			ctx, span := tracer.Start(r.Context(), "middleware "+name)
+			defer span.End()
+
+			ctx = context.WithValue(ctx, middlewareSpanContextKey, middlewareSpan{span: span, parent: parent})
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
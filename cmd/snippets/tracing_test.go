@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/assert"
+	"github.com/yousifsabah0/snippets/internal/config"
+	"github.com/yousifsabah0/snippets/internal/migrations"
+	"github.com/yousifsabah0/snippets/internal/models"
+	"github.com/yousifsabah0/snippets/internal/models/snippets"
+	"github.com/yousifsabah0/snippets/internal/models/tokens"
+	"github.com/yousifsabah0/snippets/internal/models/users"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/protobuf/proto"
+)
+
+// collector is a minimal in-process OTLP/HTTP trace receiver.
+type collector struct {
+	mu    sync.Mutex
+	spans []*tracepb.Span
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req collectortrace.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+	c.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	b, _ := proto.Marshal(&collectortrace.ExportTraceServiceResponse{})
+	w.Write(b)
+}
+
+// find returns the spans of the given trace which are called name.
+func (c *collector) find(traceID, name string) []*tracepb.Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var spans []*tracepb.Span
+	for _, s := range c.spans {
+		if s.Name == name && hex.EncodeToString(s.TraceId) == traceID {
+			spans = append(spans, s)
+		}
+	}
+
+	return spans
+}
+
+// TestTracing exports the spans of a request to an in-process collector. It
+// installs the global tracer provider, so no other test may set up tracing.
+func TestTracing(t *testing.T) {
+	c := &collector{}
+	cs := httptest.NewServer(c)
+	defer cs.Close()
+
+	shutdown, err := setupTracing(t.Context(), config.Tracing{Endpoint: cs.URL, ServiceName: "test", SampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := openDB("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m, err := migrations.New(db, models.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	app, _ := newTestApplication(t)
+	app.snippets = &snippets.SnippetModel{DB: db, Dialect: models.SQLite}
+	app.users = &users.UserModel{DB: db, Dialect: models.SQLite, Cost: bcrypt.MinCost}
+	app.tokens = &tokens.TokenModel{DB: db, Dialect: models.SQLite}
+
+	if err := app.users.Insert(t.Context(), "Alice", "alice@example.com", "pa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	id, err := app.snippets.Insert(t.Context(), 1, snippets.Draft{Title: "Traced", Content: "content", Visibility: snippets.Public, Expires: time.Now().AddDate(0, 0, 7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/snippets/view/"+strconv.Itoa(id), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("traceparent", "00-"+traceID+"-"+spanID+"-01")
+
+	code, _, _ := ts.do(t, req)
+	assert.Equal(t, code, http.StatusOK)
+
+	// Shutting down flushes the batched spans to the collector.
+	if err := shutdown(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	servers := c.find(traceID, "GET /snippets/view/{id}")
+	if len(servers) != 1 {
+		t.Fatalf("got %d server spans in the caller's trace; want 1", len(servers))
+	}
+
+	// The request continues the caller's trace.
+	server := servers[0]
+	assert.Equal(t, hex.EncodeToString(server.ParentSpanId), spanID)
+	assert.Equal(t, server.Kind, tracepb.Span_SPAN_KIND_SERVER)
+
+	for _, name := range []string{"middleware session", "middleware noSurf", "middleware authenticate", "render view.html"} {
+		if len(c.find(traceID, name)) == 0 {
+			t.Errorf("no %q span", name)
+		}
+	}
+
+	// Middleware spans end once they hand over, so the SQL query made by
+	// the handler is a child of the server span rather than of the last
+	// middleware.
+	found := false
+	for _, q := range c.find(traceID, "sql.conn.query") {
+		if slices.Equal(q.ParentSpanId, server.SpanId) {
+			found = true
+		}
+	}
+	assert.Equal(t, found, true)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/models/snippets"
+	"github.com/yousifsabah0/snippets/internal/validators"
+)
+
+const (
+	// unlockDuration is how long a protected snippet stays unlocked in a
+	// session once its password has been given.
+	unlockDuration = 30 * time.Minute
+
+	// Wrong passwords are limited both per snippet, against guessing from
+	// many addresses, and per address, against guessing at many snippets.
+	unlockWindow       = 15 * time.Minute
+	unlockSnippetLimit = 20
+	unlockIPLimit      = 10
+)
+
+// errUnlockThrottled is returned for a password that was not checked, as too
+// many wrong ones have been tried lately.
+var errUnlockThrottled = errors.New("too many wrong snippet passwords")
+
+type unlockForm struct {
+	Password             string `form:"password"`
+	validators.Validator `form:"-"`
+}
+
+// unlockKey is the session key remembering until when a snippet is unlocked.
+func unlockKey(id int) string {
+	return fmt.Sprintf("unlocked:%d", id)
+}
+
+// unlocked reports whether the request may read the content of a snippet:
+// it has no password, the user wrote it, or the session unlocked it lately.
+func (app *application) unlocked(r *http.Request, snippet snippets.Snippet) bool {
+	if !snippet.Protected() || snippet.UserID == app.authenticatedUserID(r) {
+		return true
+	}
+
+	return time.Now().Unix() < app.session.GetInt64(r.Context(), unlockKey(snippet.ID))
+}
+
+// checkSnippetPassword checks a password for a protected snippet. The check
+// is refused with errUnlockThrottled when the snippet or the client has had
+// too many wrong passwords lately.
+func (app *application) checkSnippetPassword(r *http.Request, snippet snippets.Snippet, password string) (bool, error) {
+	ip := clientIP(r)
+	if !app.unlockByIP.take(ip) {
+		return false, errUnlockThrottled
+	}
+
+	id := strconv.Itoa(snippet.ID)
+	if !app.unlockBySnippet.take(id) {
+		app.unlockByIP.refund(ip)
+		return false, errUnlockThrottled
+	}
+
+	if !snippet.CheckPassword(password) {
+		return false, nil
+	}
+
+	// Only wrong passwords count against the limits.
+	app.unlockByIP.refund(ip)
+	app.unlockBySnippet.refund(id)
+
+	return true, nil
+}
+
+// clientIP returns the address a request came from, without its port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func (app *application) renderUnlock(w http.ResponseWriter, r *http.Request, status int, snippet snippets.Snippet, form unlockForm) {
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = form
+
+	app.render(w, r, status, "unlock.html", data)
+}
+
+// snippetUnlockPost unlocks a protected snippet for the session, given its
+// password.
+func (app *application) snippetUnlockPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.viewedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if app.unlocked(r, snippet) {
+		http.Redirect(w, r, snippetURL(snippet), http.StatusSeeOther)
+		return
+	}
+
+	var form unlockForm
+	if err := app.decodePostForm(r, &form); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validators.NotBlank(form.Password), "password", "This field is required")
+
+	if form.Valid() {
+		ok, err := app.checkSnippetPassword(r, snippet, form.Password)
+		if errors.Is(err, errUnlockThrottled) {
+			form.AddNonFieldError("Too many wrong passwords, try again later")
+
+			w.Header().Set("Retry-After", strconv.Itoa(int(unlockWindow.Seconds())))
+			app.renderUnlock(w, r, http.StatusTooManyRequests, snippet, form)
+			return
+		}
+
+		form.CheckField(ok, "password", "Wrong password")
+	}
+
+	if !form.Valid() {
+		form.Password = ""
+		app.renderUnlock(w, r, http.StatusUnprocessableEntity, snippet, form)
+		return
+	}
+
+	app.session.Put(r.Context(), unlockKey(snippet.ID), time.Now().Add(unlockDuration).Unix())
+	http.Redirect(w, r, snippetURL(snippet), http.StatusSeeOther)
+}
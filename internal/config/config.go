@@ -0,0 +1,375 @@
+// Package config describes how the server is configured. A Config starts out
+// with the defaults below, which are overridden in turn by a TOML file,
+// SNIPPETS_* environment variables and command-line flags.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Config struct {
+	Addr  string `toml:"addr"`
+	Store string `toml:"store"`
+
+	Database Database `toml:"database"`
+	Server   Server   `toml:"server"`
+	TLS      TLS      `toml:"tls"`
+	Session  Session  `toml:"session"`
+	Auth     Auth     `toml:"auth"`
+	Snippets Snippets `toml:"snippets"`
+	Admin    Admin    `toml:"admin"`
+	Tracing  Tracing  `toml:"tracing"`
+}
+
+type Database struct {
+	Driver string `toml:"driver"`
+	// DSN is a secret, as it usually holds a password.
+	DSN     string `toml:"dsn"`
+	Migrate bool   `toml:"migrate"`
+}
+
+type Server struct {
+	ReadTimeout     time.Duration `toml:"read_timeout"`
+	WriteTimeout    time.Duration `toml:"write_timeout"`
+	IdleTimeout     time.Duration `toml:"idle_timeout"`
+	ShutdownTimeout time.Duration `toml:"shutdown_timeout"`
+	// DrainDelay is how long /readyz fails before the servers stop
+	// accepting connections on shutdown.
+	DrainDelay time.Duration `toml:"drain_delay"`
+}
+
+type TLS struct {
+	// Mode is either file, to serve the certificate in CertFile and
+	// KeyFile, or acme, to obtain certificates automatically.
+	Mode     string `toml:"mode"`
+	CertFile string `toml:"cert_file"`
+	KeyFile  string `toml:"key_file"`
+
+	// HTTPAddr, when set, is a plain HTTP listener which answers ACME
+	// HTTP-01 challenges and redirects everything else to HTTPS.
+	HTTPAddr string `toml:"http_addr"`
+
+	ACME ACME `toml:"acme"`
+}
+
+type ACME struct {
+	Domains  []string `toml:"domains"`
+	Email    string   `toml:"email"`
+	CacheDir string   `toml:"cache_dir"`
+	// DirectoryURL and CACertFile point the client at another CA, such as
+	// a local Pebble server in testing.
+	DirectoryURL string `toml:"directory_url"`
+	CACertFile   string `toml:"ca_cert_file"`
+}
+
+type Session struct {
+	Lifetime     time.Duration `toml:"lifetime"`
+	CookieName   string        `toml:"cookie_name"`
+	CookieDomain string        `toml:"cookie_domain"`
+	CookieSecure bool          `toml:"cookie_secure"`
+	// CookieSameSite is one of lax, strict or none.
+	CookieSameSite string `toml:"cookie_same_site"`
+}
+
+// Admin is the plain HTTP listener serving /metrics. It is disabled unless
+// Addr is set, and requires basic auth when Password is set.
+type Admin struct {
+	Addr     string `toml:"addr"`
+	Username string `toml:"username"`
+	// Password is a secret.
+	Password string `toml:"password"`
+}
+
+// Tracing exports OpenTelemetry traces over OTLP/HTTP. It is disabled unless
+// Endpoint is set, e.g. to http://localhost:4318.
+type Tracing struct {
+	Endpoint    string  `toml:"endpoint"`
+	ServiceName string  `toml:"service_name"`
+	SampleRatio float64 `toml:"sample_ratio"`
+}
+
+type Auth struct {
+	BcryptCost int `toml:"bcrypt_cost"`
+}
+
+// Snippets is the policy on how long snippets are kept.
+type Snippets struct {
+	// MaxExpiry is the longest a snippet can be kept before it expires.
+	MaxExpiry time.Duration `toml:"max_expiry"`
+	// AllowNeverExpire lets authors keep snippets until they delete them.
+	AllowNeverExpire bool `toml:"allow_never_expire"`
+}
+
+// MinExpiry is the shortest a snippet can be kept.
+const MinExpiry = time.Minute
+
+// Default returns the configuration used when nothing overrides it.
+func Default() Config {
+	return Config{
+		Addr:  ":8080",
+		Store: "sql",
+		Database: Database{
+			Driver: "mysql",
+		},
+		Server: Server{
+			ReadTimeout:     5 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			IdleTimeout:     time.Minute,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		TLS: TLS{
+			Mode:     "file",
+			CertFile: "./tls/cert.pem",
+			KeyFile:  "./tls/key.pem",
+			ACME: ACME{
+				CacheDir:     "./tls/acme",
+				DirectoryURL: autocert.DefaultACMEDirectory,
+			},
+		},
+		Session: Session{
+			Lifetime:       12 * time.Hour,
+			CookieName:     "session",
+			CookieSecure:   true,
+			CookieSameSite: "lax",
+		},
+		Auth: Auth{
+			BcryptCost: 12,
+		},
+		Snippets: Snippets{
+			MaxExpiry: 365 * 24 * time.Hour,
+		},
+		Admin: Admin{
+			Username: "admin",
+		},
+		Tracing: Tracing{
+			ServiceName: "snippets",
+			SampleRatio: 1,
+		},
+	}
+}
+
+// setting is a single configuration value that can be set from the
+// environment and the command line.
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	bind  func(fs *flag.FlagSet, name, usage string)
+}
+
+func (c *Config) settings() []setting {
+	str := func(p *string) func(*flag.FlagSet, string, string) {
+		return func(fs *flag.FlagSet, name, usage string) { fs.StringVar(p, name, *p, usage) }
+	}
+	boolean := func(p *bool) func(*flag.FlagSet, string, string) {
+		return func(fs *flag.FlagSet, name, usage string) { fs.BoolVar(p, name, *p, usage) }
+	}
+	integer := func(p *int) func(*flag.FlagSet, string, string) {
+		return func(fs *flag.FlagSet, name, usage string) { fs.IntVar(p, name, *p, usage) }
+	}
+	list := func(p *[]string) func(*flag.FlagSet, string, string) {
+		return func(fs *flag.FlagSet, name, usage string) { fs.Var((*stringList)(p), name, usage) }
+	}
+	float := func(p *float64) func(*flag.FlagSet, string, string) {
+		return func(fs *flag.FlagSet, name, usage string) { fs.Float64Var(p, name, *p, usage) }
+	}
+	duration := func(p *time.Duration) func(*flag.FlagSet, string, string) {
+		return func(fs *flag.FlagSet, name, usage string) { fs.DurationVar(p, name, *p, usage) }
+	}
+
+	return []setting{
+		{"port", "SNIPPETS_ADDR", "HTTP network address", str(&c.Addr)},
+		{"store", "SNIPPETS_STORE", "Storage backend (sql|memory)", str(&c.Store)},
+		{"db-driver", "SNIPPETS_DATABASE_DRIVER", "SQL database driver used by the sql store (mysql|sqlite|postgres)", str(&c.Database.Driver)},
+		{"dsn", "SNIPPETS_DATABASE_DSN", "Database source name (defaults to the docker-compose database for mysql and postgres, required for sqlite)", str(&c.Database.DSN)},
+		{"migrate", "SNIPPETS_DATABASE_MIGRATE", "Apply pending schema migrations before starting the server", boolean(&c.Database.Migrate)},
+		{"read-timeout", "SNIPPETS_SERVER_READ_TIMEOUT", "Maximum duration for reading a request", duration(&c.Server.ReadTimeout)},
+		{"write-timeout", "SNIPPETS_SERVER_WRITE_TIMEOUT", "Maximum duration for writing a response", duration(&c.Server.WriteTimeout)},
+		{"idle-timeout", "SNIPPETS_SERVER_IDLE_TIMEOUT", "How long to keep idle keep-alive connections open", duration(&c.Server.IdleTimeout)},
+		{"shutdown-timeout", "SNIPPETS_SERVER_SHUTDOWN_TIMEOUT", "How long to wait for in-flight requests when shutting down", duration(&c.Server.ShutdownTimeout)},
+		{"drain-delay", "SNIPPETS_SERVER_DRAIN_DELAY", "How long readiness fails before shutting down, to let load balancers drain", duration(&c.Server.DrainDelay)},
+		{"tls-cert", "SNIPPETS_TLS_CERT_FILE", "TLS certificate file", str(&c.TLS.CertFile)},
+		{"tls-key", "SNIPPETS_TLS_KEY_FILE", "TLS private key file", str(&c.TLS.KeyFile)},
+		{"tls-mode", "SNIPPETS_TLS_MODE", "Where certificates come from (file|acme)", str(&c.TLS.Mode)},
+		{"http-addr", "SNIPPETS_TLS_HTTP_ADDR", "Plain HTTP address for ACME challenges and HTTPS redirects, e.g. :80", str(&c.TLS.HTTPAddr)},
+		{"acme-domains", "SNIPPETS_TLS_ACME_DOMAINS", "Comma-separated domains to obtain certificates for", list(&c.TLS.ACME.Domains)},
+		{"acme-email", "SNIPPETS_TLS_ACME_EMAIL", "Contact email for the ACME account", str(&c.TLS.ACME.Email)},
+		{"acme-cache-dir", "SNIPPETS_TLS_ACME_CACHE_DIR", "Directory to cache ACME accounts and certificates in", str(&c.TLS.ACME.CacheDir)},
+		{"acme-directory-url", "SNIPPETS_TLS_ACME_DIRECTORY_URL", "ACME directory URL of the certificate authority", str(&c.TLS.ACME.DirectoryURL)},
+		{"acme-ca-cert", "SNIPPETS_TLS_ACME_CA_CERT_FILE", "Extra CA certificate to trust when talking to the ACME server", str(&c.TLS.ACME.CACertFile)},
+		{"session-lifetime", "SNIPPETS_SESSION_LIFETIME", "How long a session lasts", duration(&c.Session.Lifetime)},
+		{"session-cookie-name", "SNIPPETS_SESSION_COOKIE_NAME", "Name of the session cookie", str(&c.Session.CookieName)},
+		{"session-cookie-domain", "SNIPPETS_SESSION_COOKIE_DOMAIN", "Domain attribute of the session cookie", str(&c.Session.CookieDomain)},
+		{"session-cookie-secure", "SNIPPETS_SESSION_COOKIE_SECURE", "Only send the session cookie over HTTPS", boolean(&c.Session.CookieSecure)},
+		{"session-cookie-same-site", "SNIPPETS_SESSION_COOKIE_SAME_SITE", "SameSite attribute of the session cookie (lax|strict|none)", str(&c.Session.CookieSameSite)},
+		{"admin-addr", "SNIPPETS_ADMIN_ADDR", "Address of the admin listener serving /metrics, e.g. 127.0.0.1:9090", str(&c.Admin.Addr)},
+		{"admin-username", "SNIPPETS_ADMIN_USERNAME", "Basic auth username for the admin listener", str(&c.Admin.Username)},
+		{"admin-password", "SNIPPETS_ADMIN_PASSWORD", "Basic auth password for the admin listener; no auth if empty", str(&c.Admin.Password)},
+		{"otlp-endpoint", "SNIPPETS_TRACING_ENDPOINT", "OTLP/HTTP endpoint to export traces to, e.g. http://localhost:4318; tracing is off if empty", str(&c.Tracing.Endpoint)},
+		{"trace-service-name", "SNIPPETS_TRACING_SERVICE_NAME", "Service name reported in traces", str(&c.Tracing.ServiceName)},
+		{"trace-sample-ratio", "SNIPPETS_TRACING_SAMPLE_RATIO", "Fraction of new traces to sample, between 0 and 1", float(&c.Tracing.SampleRatio)},
+		{"bcrypt-cost", "SNIPPETS_AUTH_BCRYPT_COST", "bcrypt cost used to hash passwords", integer(&c.Auth.BcryptCost)},
+		{"snippet-max-expiry", "SNIPPETS_SNIPPETS_MAX_EXPIRY", "Longest a snippet can be kept before it expires", duration(&c.Snippets.MaxExpiry)},
+		{"snippet-never-expire", "SNIPPETS_SNIPPETS_ALLOW_NEVER_EXPIRE", "Let authors keep snippets until they delete them", boolean(&c.Snippets.AllowNeverExpire)},
+	}
+}
+
+// RegisterFlags defines a flag on fs for every setting, bound to c.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	for _, s := range c.settings() {
+		s.bind(fs, s.flag, fmt.Sprintf("%s [%s]", s.usage, s.env))
+	}
+}
+
+// Load resets c to the defaults and applies, in order, the TOML file at path
+// (if any), the environment variables found by lookupEnv and the flags that
+// were set explicitly when fs was parsed. fs must have been registered with
+// c.RegisterFlags. The result is validated.
+func (c *Config) Load(fs *flag.FlagSet, path string, lookupEnv func(string) (string, bool)) error {
+	// Remember the flags that were given, as resetting c below undoes
+	// them.
+	set := map[string]string{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = f.Value.String() })
+
+	*c = Default()
+
+	if path != "" {
+		if err := c.loadFile(path); err != nil {
+			return err
+		}
+	}
+
+	settings := c.settings()
+
+	for _, s := range settings {
+		if v, ok := lookupEnv(s.env); ok {
+			if err := fs.Set(s.flag, v); err != nil {
+				return fmt.Errorf("config: %s: %w", s.env, err)
+			}
+		}
+	}
+
+	for _, s := range settings {
+		if v, ok := set[s.flag]; ok {
+			if err := fs.Set(s.flag, v); err != nil {
+				return fmt.Errorf("config: -%s: %w", s.flag, err)
+			}
+		}
+	}
+
+	return c.Validate()
+}
+
+func (c *Config) loadFile(path string) error {
+	md, err := toml.DecodeFile(path, c)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return fmt.Errorf("config: %s: unknown setting %q", path, undecoded[0].String())
+	}
+
+	return nil
+}
+
+// Validate reports every invalid setting in c.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, key, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("config: %s: %s", key, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	check(c.Addr != "", "addr", "must not be empty")
+	check(slices.Contains([]string{"sql", "memory"}, c.Store), "store", "must be sql or memory, not %q", c.Store)
+	check(slices.Contains([]string{"mysql", "sqlite", "postgres"}, c.Database.Driver), "database.driver", "must be mysql, sqlite or postgres, not %q", c.Database.Driver)
+
+	check(c.Server.ReadTimeout > 0, "server.read_timeout", "must be positive")
+	check(c.Server.WriteTimeout > 0, "server.write_timeout", "must be positive")
+	check(c.Server.IdleTimeout > 0, "server.idle_timeout", "must be positive")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout", "must be positive")
+	check(c.Server.DrainDelay >= 0, "server.drain_delay", "must not be negative")
+
+	switch c.TLS.Mode {
+	case "file":
+		check(c.TLS.CertFile != "", "tls.cert_file", "must not be empty")
+		check(c.TLS.KeyFile != "", "tls.key_file", "must not be empty")
+	case "acme":
+		check(len(c.TLS.ACME.Domains) > 0, "tls.acme.domains", "must list at least one domain")
+		check(c.TLS.ACME.CacheDir != "", "tls.acme.cache_dir", "must not be empty")
+		check(c.TLS.ACME.DirectoryURL != "", "tls.acme.directory_url", "must not be empty")
+	default:
+		check(false, "tls.mode", "must be file or acme, not %q", c.TLS.Mode)
+	}
+
+	check(c.Session.Lifetime > 0, "session.lifetime", "must be positive")
+	check(c.Session.CookieName != "", "session.cookie_name", "must not be empty")
+	check(slices.Contains([]string{"lax", "strict", "none"}, c.Session.CookieSameSite), "session.cookie_same_site", "must be lax, strict or none, not %q", c.Session.CookieSameSite)
+	check(c.Session.CookieSameSite != "none" || c.Session.CookieSecure, "session.cookie_same_site", "none requires session.cookie_secure")
+
+	check(c.Admin.Password == "" || c.Admin.Username != "", "admin.username", "must not be empty when admin.password is set")
+
+	if c.Tracing.Endpoint != "" {
+		u, err := url.Parse(c.Tracing.Endpoint)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "tracing.endpoint", "must be an http or https URL, not %q", c.Tracing.Endpoint)
+	}
+	check(c.Tracing.ServiceName != "", "tracing.service_name", "must not be empty")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "must be between 0 and 1")
+
+	check(c.Auth.BcryptCost >= bcrypt.MinCost && c.Auth.BcryptCost <= bcrypt.MaxCost, "auth.bcrypt_cost", "must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+
+	check(c.Snippets.MaxExpiry >= MinExpiry, "snippets.max_expiry", "must be at least %s", MinExpiry)
+
+	return errors.Join(errs...)
+}
+
+// stringList is a flag.Value for comma-separated lists.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = nil
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+
+	return nil
+}
+
+const redacted = "[redacted]"
+
+// Redacted returns a copy of c with secrets replaced, fit for printing.
+func (c Config) Redacted() Config {
+	if c.Database.DSN != "" {
+		c.Database.DSN = redacted
+	}
+
+	if c.Admin.Password != "" {
+		c.Admin.Password = redacted
+	}
+
+	return c
+}
+
+// Print writes c as TOML, with secrets redacted.
+func (c Config) Print(w io.Writer) error {
+	return toml.NewEncoder(w).Encode(c.Redacted())
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/assert"
+)
+
+func load(t *testing.T, file string, env map[string]string, args ...string) (Config, error) {
+	t.Helper()
+
+	var path string
+	if file != "" {
+		path = filepath.Join(t.TempDir(), "snippets.toml")
+		if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := Default()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cfg.RegisterFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+
+	err := cfg.Load(fs, path, func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	})
+
+	return cfg, err
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := `
+addr = ":9000"
+store = "memory"
+
+[session]
+lifetime = "1h"
+cookie_name = "file"
+`
+	env := map[string]string{
+		"SNIPPETS_STORE":               "sql",
+		"SNIPPETS_SESSION_COOKIE_NAME": "env",
+	}
+
+	cfg, err := load(t, file, env, "-session-cookie-name", "flag")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, cfg.Addr, ":9000")
+	assert.Equal(t, cfg.Store, "sql")
+	assert.Equal(t, cfg.Session.Lifetime, time.Hour)
+	assert.Equal(t, cfg.Session.CookieName, "flag")
+	assert.Equal(t, cfg.Server.WriteTimeout, Default().Server.WriteTimeout)
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{
+			name: "Unknown file setting",
+			file: "[session]\nlifetme = \"1h\"\n",
+			want: `unknown setting "session.lifetme"`,
+		},
+		{
+			name: "Bad environment value",
+			env:  map[string]string{"SNIPPETS_SESSION_LIFETIME": "forever"},
+			want: "SNIPPETS_SESSION_LIFETIME",
+		},
+		{
+			name: "Invalid driver",
+			args: []string{"-db-driver", "oracle"},
+			want: "database.driver",
+		},
+		{
+			name: "SameSite none without secure",
+			args: []string{"-session-cookie-same-site", "none", "-session-cookie-secure=false"},
+			want: "session.cookie_same_site",
+		},
+		{
+			name: "ACME without domains",
+			args: []string{"-tls-mode", "acme"},
+			want: "tls.acme.domains",
+		},
+		{
+			name: "Snippet expiry",
+			env:  map[string]string{"SNIPPETS_SNIPPETS_MAX_EXPIRY": "30s"},
+			want: "snippets.max_expiry",
+		},
+		{
+			name: "Bcrypt cost",
+			env:  map[string]string{"SNIPPETS_AUTH_BCRYPT_COST": "99"},
+			want: "auth.bcrypt_cost",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(t, tt.file, tt.env, tt.args...)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			assert.StringContains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestLoadList(t *testing.T) {
+	env := map[string]string{"SNIPPETS_TLS_ACME_DOMAINS": "example.com, www.example.com,"}
+
+	cfg, err := load(t, "", env, "-tls-mode", "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, strings.Join(cfg.TLS.ACME.Domains, " "), "example.com www.example.com")
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Database.DSN = "web:hunter2@/snippets"
+	cfg.Admin.Password = "hunter2"
+
+	var b strings.Builder
+	if err := cfg.Print(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(b.String(), "hunter2") {
+		t.Errorf("printed config contains a password:\n%s", b.String())
+	}
+	assert.StringContains(t, b.String(), `dsn = "[redacted]"`)
+}
@@ -0,0 +1,288 @@
+// Package diff computes line-level differences between two texts and groups
+// them into unified diff hunks.
+package diff
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Line is a single line of an edit script. OldNo and NewNo are the 1-based
+// line numbers in the old and new text, and are 0 when the line does not
+// exist on that side.
+type Line struct {
+	Op    Op
+	Text  string
+	OldNo int
+	NewNo int
+}
+
+// Prefix returns the marker a unified diff puts in front of the line.
+func (l Line) Prefix() string {
+	switch l.Op {
+	case Insert:
+		return "+"
+	case Delete:
+		return "-"
+	default:
+		return " "
+	}
+}
+
+// Hunk is a run of changed lines surrounded by unchanged context lines.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+// Header returns the "@@ -a,b +c,d @@" line of the hunk.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// Lines returns the full edit script turning a into b, with as few deleted
+// and inserted lines as possible. It uses the linear space variant of Myers'
+// algorithm, so its time grows with the number of lines times the number of
+// differences, and its memory only with the number of lines. Blocks with more
+// than about 2*maxCost differences are replaced whole instead.
+func Lines(a, b string) []Line {
+	x, y := split(a), split(b)
+
+	// The searches use the diagonals -D-1 to D+1.
+	size := 2*(min((len(x)+len(y)+1)/2, maxCost)+1) + 1
+	d := differ{
+		x:     x,
+		y:     y,
+		vf:    make([]int, size),
+		vb:    make([]int, size),
+		off:   size / 2,
+		lines: make([]Line, 0, len(x)+len(y)),
+	}
+	d.compare(0, len(x), 0, len(y))
+
+	// Show every changed block as its deletions followed by its insertions,
+	// as diff(1) does, rather than in the order the search found them.
+	for i := 0; i < len(d.lines); {
+		if d.lines[i].Op == Equal {
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(d.lines) && d.lines[j].Op != Equal {
+			j++
+		}
+		slices.SortStableFunc(d.lines[i:j], func(l, m Line) int {
+			return cmp.Compare(m.Op, l.Op)
+		})
+		i = j
+	}
+
+	return d.lines
+}
+
+// Unified groups the edit script turning a into b into hunks, keeping up to
+// context unchanged lines around every change. It returns nil when a and b
+// have the same lines.
+func Unified(a, b string, context int) []Hunk {
+	lines := Lines(a, b)
+
+	var hunks []Hunk
+	start, end := -1, -1
+	for i, line := range lines {
+		if line.Op == Equal {
+			continue
+		}
+
+		lo, hi := max(i-context, 0), min(i+context+1, len(lines))
+		if start >= 0 && lo <= end {
+			end = hi
+			continue
+		}
+
+		if start >= 0 {
+			hunks = append(hunks, newHunk(lines, start, end))
+		}
+		start, end = lo, hi
+	}
+
+	if start >= 0 {
+		hunks = append(hunks, newHunk(lines, start, end))
+	}
+
+	return hunks
+}
+
+func newHunk(lines []Line, start, end int) Hunk {
+	h := Hunk{Lines: lines[start:end]}
+
+	// Count the old and new lines that precede the hunk so that empty sides
+	// get the line number they would be inserted after, as diff(1) does.
+	for _, line := range lines[:start] {
+		if line.Op != Insert {
+			h.OldStart++
+		}
+		if line.Op != Delete {
+			h.NewStart++
+		}
+	}
+
+	for _, line := range h.Lines {
+		if line.Op != Insert {
+			h.OldLines++
+		}
+		if line.Op != Delete {
+			h.NewLines++
+		}
+	}
+
+	if h.OldLines > 0 {
+		h.OldStart++
+	}
+	if h.NewLines > 0 {
+		h.NewStart++
+	}
+
+	return h
+}
+
+// maxCost is how many differences middleSnake looks through before giving
+// up, which keeps Lines fast on long texts that have little in common.
+const maxCost = 1024
+
+// differ holds the state of Lines while it works out an edit script.
+type differ struct {
+	x, y []string
+	// vf and vb hold the furthest reaching forward and backward paths,
+	// indexed by diagonal plus off.
+	vf, vb []int
+	off    int
+	lines  []Line
+}
+
+// compare appends the edit script turning x[xlo:xhi] into y[ylo:yhi].
+func (d *differ) compare(xlo, xhi, ylo, yhi int) {
+	// Common prefixes and suffixes are by far the usual case for edited
+	// snippets. Stripping them also leaves middleSnake with at least two
+	// differences, which it always splits into smaller problems.
+	prefix := 0
+	for xlo+prefix < xhi && ylo+prefix < yhi && d.x[xlo+prefix] == d.y[ylo+prefix] {
+		prefix++
+	}
+	d.equal(xlo, ylo, prefix)
+	xlo, ylo = xlo+prefix, ylo+prefix
+
+	suffix := 0
+	for xlo < xhi-suffix && ylo < yhi-suffix && d.x[xhi-1-suffix] == d.y[yhi-1-suffix] {
+		suffix++
+	}
+	xhi, yhi = xhi-suffix, yhi-suffix
+
+	if xs, ys, xe, ye, ok := d.middleSnake(xlo, xhi, ylo, yhi); ok {
+		d.compare(xlo, xs, ylo, ys)
+		d.equal(xs, ys, xe-xs)
+		d.compare(xe, xhi, ye, yhi)
+	} else {
+		for i := xlo; i < xhi; i++ {
+			d.lines = append(d.lines, Line{Op: Delete, Text: d.x[i], OldNo: i + 1})
+		}
+		for j := ylo; j < yhi; j++ {
+			d.lines = append(d.lines, Line{Op: Insert, Text: d.y[j], NewNo: j + 1})
+		}
+	}
+
+	d.equal(xhi, yhi, suffix)
+}
+
+// equal appends the n equal lines starting at x[i] and y[j].
+func (d *differ) equal(i, j, n int) {
+	for k := range n {
+		d.lines = append(d.lines, Line{Op: Equal, Text: d.x[i+k], OldNo: i + k + 1, NewNo: j + k + 1})
+	}
+}
+
+// middleSnake searches forwards from the start and backwards from the end of
+// x[xlo:xhi] and y[ylo:yhi] at once, and returns the run of equal lines,
+// from (xs, ys) to (xe, ye), on which the two searches meet. That run lies
+// on a shortest edit script. It reports false when either side is empty, or
+// when the searches go past maxCost differences without meeting.
+func (d *differ) middleSnake(xlo, xhi, ylo, yhi int) (xs, ys, xe, ye int, ok bool) {
+	n, m := xhi-xlo, yhi-ylo
+	if n == 0 || m == 0 {
+		return 0, 0, 0, 0, false
+	}
+
+	delta := n - m
+	odd := delta&1 == 1
+
+	vf, vb, off := d.vf, d.vb, d.off
+	vf[off+1], vb[off+1] = 0, 0
+
+	for D := 0; D <= min((n+m+1)/2, maxCost); D++ {
+		for k := -D; k <= D; k += 2 {
+			var x int
+			if k == -D || (k != D && vf[off+k-1] < vf[off+k+1]) {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && d.x[xlo+x] == d.y[ylo+y] {
+				x++
+				y++
+			}
+			vf[off+k] = x
+
+			if odd && delta-k >= -(D-1) && delta-k <= D-1 && x+vb[off+delta-k] >= n {
+				return xlo + x0, ylo + y0, xlo + x, ylo + y, true
+			}
+		}
+
+		// The backward search runs on the reversed lines, so its x and y
+		// count from the ends.
+		for k := -D; k <= D; k += 2 {
+			var x int
+			if k == -D || (k != D && vb[off+k-1] < vb[off+k+1]) {
+				x = vb[off+k+1]
+			} else {
+				x = vb[off+k-1] + 1
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && d.x[xhi-1-x] == d.y[yhi-1-y] {
+				x++
+				y++
+			}
+			vb[off+k] = x
+
+			if !odd && delta-k >= -D && delta-k <= D && x+vf[off+delta-k] >= n {
+				return xhi - x, yhi - y, xhi - x0, yhi - y0, true
+			}
+		}
+	}
+
+	return 0, 0, 0, 0, false
+}
+
+func split(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,182 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/yousifsabah0/snippets/internal/assert"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name    string
+		a       string
+		b       string
+		context int
+		want    string
+	}{
+		{
+			name:    "Identical",
+			a:       "one\ntwo\n",
+			b:       "one\ntwo",
+			context: 3,
+			want:    "",
+		},
+		{
+			name:    "Changed line",
+			a:       "1\n2\n3\n4\n5\n6\n7\n8\n9",
+			b:       "1\n2\n3\n4\nfive\n6\n7\n8\n9",
+			context: 3,
+			want:    "@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name:    "Insert into empty",
+			a:       "",
+			b:       "hello\nworld",
+			context: 3,
+			want:    "@@ -0,0 +1,2 @@\n+hello\n+world\n",
+		},
+		{
+			name:    "Separate hunks",
+			a:       "a\nb\nc\nd\ne\nf\ng\nh\ni\nj",
+			b:       "A\nb\nc\nd\ne\nf\ng\nh\ni\nJ",
+			context: 1,
+			want:    "@@ -1,2 +1,2 @@\n-a\n+A\n b\n@@ -9,2 +9,2 @@\n i\n-j\n+J\n",
+		},
+		{
+			name:    "Deleted line",
+			a:       "a\nb\nc",
+			b:       "a\nc",
+			context: 3,
+			want:    "@@ -1,3 +1,2 @@\n a\n-b\n c\n",
+		},
+		{
+			name:    "No context",
+			a:       "a\nb\nc\nd",
+			b:       "a\nB\nc\nd\ne",
+			context: 0,
+			want:    "@@ -2,1 +2,1 @@\n-b\n+B\n@@ -4,0 +5,1 @@\n+e\n",
+		},
+		{
+			name:    "Replaced block",
+			a:       "a\nb\nc\nd",
+			b:       "a\nB\nC\nd",
+			context: 0,
+			want:    "@@ -2,2 +2,2 @@\n-b\n-c\n+B\n+C\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sb strings.Builder
+			for _, h := range Unified(test.a, test.b, test.context) {
+				sb.WriteString(h.Header() + "\n")
+				for _, line := range h.Lines {
+					sb.WriteString(line.Prefix() + line.Text + "\n")
+				}
+			}
+
+			assert.Equal(t, sb.String(), test.want)
+		})
+	}
+}
+
+func TestLinesShortest(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+
+	for i := range 2000 {
+		a, b := randomText(r, r.IntN(12)), randomText(r, r.IntN(12))
+
+		lines := Lines(a, b)
+		checkScript(t, a, b, lines)
+
+		equal := 0
+		for _, line := range lines {
+			if line.Op == Equal {
+				equal++
+			}
+		}
+		if want := lcsLength(split(a), split(b)); equal != want {
+			t.Fatalf("case %d: %q to %q keeps %d lines, want %d", i, a, b, equal, want)
+		}
+	}
+}
+
+func TestLinesLarge(t *testing.T) {
+	var old, changed, other strings.Builder
+	for i := range 50000 {
+		fmt.Fprintf(&old, "line %d\n", i)
+		if i%1000 == 0 {
+			fmt.Fprintf(&changed, "edited %d\n", i)
+		} else {
+			fmt.Fprintf(&changed, "line %d\n", i)
+		}
+		fmt.Fprintf(&other, "other %d\n", i)
+	}
+
+	// Few differences give a shortest script.
+	lines := Lines(old.String(), changed.String())
+	checkScript(t, old.String(), changed.String(), lines)
+	assert.Equal(t, len(Unified(old.String(), changed.String(), 0)), 50)
+
+	// Texts with nothing in common are replaced whole rather than searched.
+	lines = Lines(old.String(), other.String())
+	checkScript(t, old.String(), other.String(), lines)
+	assert.Equal(t, len(lines), 100000)
+}
+
+// checkScript fails the test unless lines turns a into b, with the right line
+// numbers.
+func checkScript(t *testing.T, a, b string, lines []Line) {
+	t.Helper()
+
+	x, y := split(a), split(b)
+	i, j := 0, 0
+	for _, line := range lines {
+		if line.Op != Insert {
+			if i >= len(x) || line.Text != x[i] || line.OldNo != i+1 {
+				t.Fatalf("%q to %q: bad old line %+v", a, b, line)
+			}
+			i++
+		}
+		if line.Op != Delete {
+			if j >= len(y) || line.Text != y[j] || line.NewNo != j+1 {
+				t.Fatalf("%q to %q: bad new line %+v", a, b, line)
+			}
+			j++
+		}
+	}
+
+	if i != len(x) || j != len(y) {
+		t.Fatalf("%q to %q: script covers %d and %d lines", a, b, i, j)
+	}
+}
+
+func randomText(r *rand.Rand, n int) string {
+	var sb strings.Builder
+	for range n {
+		sb.WriteString(string(rune('a'+r.IntN(3))) + "\n")
+	}
+	return sb.String()
+}
+
+func lcsLength(x, y []string) int {
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	return lcs[0][0]
+}
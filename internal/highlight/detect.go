@@ -0,0 +1,93 @@
+package highlight
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2/lexers"
+)
+
+// rule detects a language when all of its patterns match.
+type rule struct {
+	language string
+	patterns []*regexp.Regexp
+}
+
+func newRule(language string, patterns ...string) rule {
+	r := rule{language: language}
+	for _, p := range patterns {
+		r.patterns = append(r.patterns, regexp.MustCompile(p))
+	}
+
+	return r
+}
+
+func (r rule) match(content string) bool {
+	for _, p := range r.patterns {
+		if !p.MatchString(content) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// rules are tried in order, so the more specific ones come first. The
+// lexers have their own guesses, but they are too easily fooled by short
+// snippets to be tried first.
+var rules = []rule{
+	newRule("Bash", `\A#!.*\b(ba|z)?sh\b`),
+	newRule("Python", `\A#!.*\bpython`),
+	newRule("JavaScript", `\A#!.*\bnode\b`),
+	newRule("Ruby", `\A#!.*\bruby\b`),
+	newRule("PHP", `<\?php`),
+	newRule("HTML", `(?i)<!doctype html|<html[\s>]`),
+	newRule("Diff", `(?m)^(diff --git |--- .*\n\+\+\+ )`, `(?m)^@@ `),
+	newRule("Docker", `(?m)^FROM \S+`, `(?m)^(RUN|CMD|COPY|ADD|ENTRYPOINT|WORKDIR) `),
+	newRule("Go", `(?m)^package \w+\s*$`),
+	newRule("Go", `(?m)^func (\(\w+ \*?\w+\) )?\w+\(`),
+	newRule("Rust", `(?m)^\s*(pub )?fn \w+`, `(?m)\blet mut\b|\bprintln!|\bimpl\b|^use \w+::`),
+	newRule("C++", `(?m)^#include\s*[<"]`, `std::|\bcout\b|\bclass \w+`),
+	newRule("C", `(?m)^#include\s*[<"]`),
+	newRule("C#", `(?m)^using System\b|\bConsole\.Write`),
+	newRule("Java", `\bpublic (static )?(class|void|final)\b|\bSystem\.out\.`),
+	newRule("Kotlin", `(?m)^\s*fun \w+\(`),
+	newRule("Swift", `(?m)^import (Foundation|UIKit|SwiftUI)\b`),
+	newRule("TypeScript", `(?m)^\s*(export )?(interface|type) \w+|: (string|number|boolean)\b`),
+	newRule("JavaScript", `(?m)\bconsole\.log\(|\bfunction\s*\w*\(|^\s*(const|let|var) \w+ = |=>`),
+	newRule("Python", `(?m)^\s*(def|class) \w+.*:\s*$|^(import \w+|from [\w.]+ import \w+)\s*$`),
+	newRule("Ruby", `(?m)^\s*def \w+`, `(?m)^\s*end\s*$`),
+	newRule("PowerShell", `\b(Get|Set|New|Remove|Write)-[A-Z]\w+`),
+	newRule("SQL", `(?im)^\s*(SELECT|INSERT INTO|UPDATE|DELETE FROM|CREATE (TABLE|INDEX)|ALTER TABLE)\b`),
+	newRule("Makefile", `(?m)^[\w./-]+:.*\n\t`),
+	newRule("CSS", `(?m)^[^{}\n]+\{\s*$`, `(?m)^\s+[\w-]+:\s*[^;\n]+;\s*$`),
+	newRule("TOML", `(?m)^\[[\w.-]+\]\s*$`, `(?m)^[\w-]+ = `),
+	newRule("YAML", `\A(---\n)?[\w.-]+:(\s|$)`),
+	newRule("Bash", `(?m)^\s*(sudo|echo|export|if \[|for \w+ in) `),
+}
+
+// Detect guesses the language of content, returning the empty language when
+// it has no good guess.
+func Detect(content string) string {
+	// JSON is cheap to check for certain.
+	if trimmed := strings.TrimSpace(content); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		if json.Valid([]byte(trimmed)) {
+			return "JSON"
+		}
+	}
+
+	for _, r := range rules {
+		if r.match(content) {
+			return r.language
+		}
+	}
+
+	if lexer := lexers.Analyse(content); lexer != nil {
+		if name := lexer.Config().Name; Supported(name) {
+			return name
+		}
+	}
+
+	return ""
+}
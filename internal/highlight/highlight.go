@@ -0,0 +1,88 @@
+// Package highlight renders snippet content as HTML with syntax highlighting
+// and numbered lines that can be linked to. The markup only uses CSS classes,
+// styled by the stylesheet from WriteCSS, so it works under a Content-Security-Policy
+// that forbids inline styles.
+package highlight
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// Auto is the choice of language that asks for it to be detected from the
+// content instead.
+const Auto = "auto"
+
+// Languages are the languages snippets can be highlighted as, by their
+// lexer names. The empty language is plain text.
+var Languages = []string{
+	"Bash", "C", "C#", "C++", "CSS", "Diff", "Docker", "Go", "HTML", "Java",
+	"JavaScript", "JSON", "Kotlin", "Makefile", "PHP", "PowerShell", "Python",
+	"Ruby", "Rust", "SQL", "Swift", "TOML", "TypeScript", "YAML",
+}
+
+// Supported reports whether content can be highlighted as language.
+func Supported(language string) bool {
+	return language == "" || slices.Contains(Languages, language)
+}
+
+var (
+	style     = styles.Get("github")
+	formatter = html.New(
+		html.WithClasses(true),
+		html.TabWidth(4),
+		html.WithLineNumbers(true),
+		// Lines get the IDs L1, L2 and so on, and their numbers link to them.
+		html.WithLinkableLineNumbers(true, "L"),
+	)
+)
+
+// HTML highlights content as language, or renders it as plain text when
+// the language is empty or not supported. Either way its lines are numbered.
+func HTML(content, language string) (template.HTML, error) {
+	lexer := lexers.Fallback
+	if language != "" && Supported(language) {
+		if l := lexers.Get(language); l != nil {
+			lexer = l
+		}
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, content)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := formatter.Format(&buf, style, iterator); err != nil {
+		return "", err
+	}
+
+	return template.HTML(buf.String()), nil
+}
+
+var css = sync.OnceValues(func() ([]byte, error) {
+	var buf bytes.Buffer
+	err := formatter.WriteCSS(&buf, style)
+
+	return buf.Bytes(), err
+})
+
+// WriteCSS writes the stylesheet for the classes in the HTML.
+func WriteCSS(w io.Writer) error {
+	b, err := css()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+
+	return err
+}
@@ -0,0 +1,72 @@
+package highlight
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/yousifsabah0/snippets/internal/assert"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"Go", "package main\n\nfunc main() {}\n", "Go"},
+		{"Shebang", "#!/usr/bin/env bash\nset -eu\n", "Bash"},
+		{"Shell", "export KUBECONFIG=~/.kube/prod\necho done\n", "Bash"},
+		{"Python", "def greet(name):\n    return f\"hi {name}\"\n", "Python"},
+		{"JavaScript", "const add = (a, b) => a + b;\n", "JavaScript"},
+		{"JavaScript after a comment", "// Retry settings\nconst retries = 3;\n", "JavaScript"},
+		{"TypeScript", "function add(a: number, b: number) {\n  return a + b\n}\n", "TypeScript"},
+		{"SQL", "select id, title\nfrom snippets\nwhere expires > now();\n", "SQL"},
+		{"HTML", "<!doctype html>\n<html><body></body></html>\n", "HTML"},
+		{"JSON", `{"title": "Hello", "tags": ["a"]}`, "JSON"},
+		{"Rust", "fn main() {\n    println!(\"hi\");\n}\n", "Rust"},
+		{"Rust after a comment", "// Entry point\nuse std::io;\n\nfn main() {}\n", "Rust"},
+		{"C", "#include <stdio.h>\n\nint main(void) { return 0; }\n", "C"},
+		{"Dockerfile", "FROM golang:1.24\nRUN go build ./...\n", "Docker"},
+		{"Diff", "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-a\n+b\n", "Diff"},
+		{"YAML", "apiVersion: v1\nkind: Pod\n", "YAML"},
+		{"Prose", "Restart the pods, then check the dashboards.", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, Detect(tt.content), tt.want)
+		})
+	}
+}
+
+func TestHTML(t *testing.T) {
+	out, err := HTML("package main\n\nfunc main() {}\n", "Go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.StringContains(t, string(out), `<span class="ln" id="L3"><a class="lnlinks" href="#L3">3</a></span>`)
+	assert.StringContains(t, string(out), `<span class="kd">func</span>`)
+	assert.Equal(t, strings.Contains(string(out), "style="), false)
+
+	// Plain text and unsupported languages are escaped and numbered all the same.
+	for _, language := range []string{"", "Brainfuck"} {
+		out, err = HTML("<b>bold</b>", language)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.StringContains(t, string(out), `id="L1"`)
+		assert.StringContains(t, string(out), "&lt;b&gt;bold&lt;/b&gt;")
+	}
+}
+
+func TestWriteCSS(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCSS(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.StringContains(t, buf.String(), ".chroma .kd")
+}
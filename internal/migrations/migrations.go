@@ -0,0 +1,291 @@
+// Package migrations holds the database schema as numbered SQL migrations,
+// embedded into the binary, and applies them to a database.
+//
+// Every dialect has its own directory of migrations, with one
+// NNNN_name.up.sql and one NNNN_name.down.sql file per version. The applied
+// versions are recorded in the schema_migrations table.
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/models"
+)
+
+//go:embed mysql sqlite postgres
+var files embed.FS
+
+var dirs = map[models.Dialect]string{
+	models.MySQL:    "mysql",
+	models.SQLite:   "sqlite",
+	models.Postgres: "postgres",
+}
+
+var createTable = map[models.Dialect]string{
+	models.MySQL:    `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL PRIMARY KEY, applied DATETIME NOT NULL)`,
+	models.SQLite:   `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL PRIMARY KEY, applied DATETIME NOT NULL)`,
+	models.Postgres: `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL PRIMARY KEY, applied TIMESTAMPTZ NOT NULL)`,
+}
+
+// Migration is one version of the schema.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status reports whether a migration has been applied, and when.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	DB         *sql.DB
+	Dialect    models.Dialect
+	Migrations []Migration
+
+	// Logf, when set, is called as every migration is applied or rolled
+	// back.
+	Logf func(format string, args ...any)
+}
+
+// New returns a Migrator for the embedded migrations of dialect.
+func New(db *sql.DB, dialect models.Dialect) (*Migrator, error) {
+	migrations, err := load(files, dirs[dialect])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{DB: db, Dialect: dialect, Migrations: migrations}, nil
+}
+
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+
+		base, direction, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migrations: unexpected file %s", name)
+		}
+
+		prefix, label, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migrations: bad version in %s", name)
+		}
+
+		b, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		}
+
+		if direction == "up" {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+
+	var migrations []Migration
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migrations: version %d needs both an up and a down file", m.Version)
+		}
+
+		migrations = append(migrations, *m)
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migrations: version %d is missing", i+1)
+		}
+	}
+
+	return migrations, nil
+}
+
+// Latest returns the highest known version.
+func (m *Migrator) Latest() int {
+	return len(m.Migrations)
+}
+
+// Version returns the highest applied version, or 0 for an empty database.
+func (m *Migrator) Version() (int, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	version := 0
+	for v := range applied {
+		version = max(version, v)
+	}
+
+	return version, nil
+}
+
+// Status lists every known migration along with whether it was applied.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, migration := range m.Migrations {
+		at, ok := applied[migration.Version]
+		statuses = append(statuses, Status{Migration: migration, Applied: ok, AppliedAt: at})
+	}
+
+	return statuses, nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up() error {
+	return m.To(m.Latest())
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down() error {
+	version, err := m.Version()
+	if err != nil {
+		return err
+	}
+
+	if version == 0 {
+		return nil
+	}
+
+	return m.To(version - 1)
+}
+
+// To migrates the database up or down until version is the highest applied
+// one.
+func (m *Migrator) To(version int) error {
+	if version < 0 || version > m.Latest() {
+		return fmt.Errorf("migrations: unknown version %d, latest is %d", version, m.Latest())
+	}
+
+	current, err := m.Version()
+	if err != nil {
+		return err
+	}
+
+	for v := current + 1; v <= version; v++ {
+		if err := m.apply(m.Migrations[v-1], true); err != nil {
+			return err
+		}
+	}
+
+	for v := current; v > version; v-- {
+		if err := m.apply(m.Migrations[v-1], false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// apply runs a single migration and records it. The statements and the
+// bookkeeping share a transaction, which makes migrations atomic on SQLite
+// and Postgres; MySQL commits DDL statements implicitly.
+func (m *Migrator) apply(migration Migration, up bool) error {
+	script, record := migration.Down, `DELETE FROM schema_migrations WHERE version = ?`
+	args := []any{migration.Version}
+	if up {
+		script, record = migration.Up, `INSERT INTO schema_migrations (version, applied) VALUES (?, ?)`
+		args = append(args, time.Now().UTC())
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range strings.Split(script, ";") {
+		if strings.TrimSpace(stmt) == "" {
+			continue
+		}
+
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("migrations: version %d (%s): %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	if _, err := tx.Exec(m.Dialect.Rebind(record), args...); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if m.Logf != nil {
+		direction := "applied"
+		if !up {
+			direction = "rolled back"
+		}
+
+		m.Logf("%s migration %04d_%s", direction, migration.Version, migration.Name)
+	}
+
+	return nil
+}
+
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	if _, err := m.DB.Exec(createTable[m.Dialect]); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.Query(`SELECT version, applied FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var (
+			version int
+			at      time.Time
+		)
+
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+
+		applied[version] = at
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
@@ -0,0 +1,146 @@
+package migrations
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/yousifsabah0/snippets/internal/assert"
+	"github.com/yousifsabah0/snippets/internal/models"
+	_ "modernc.org/sqlite"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	var latest []int
+
+	for _, dir := range dirs {
+		m, err := load(files, dir)
+		if err != nil {
+			t.Fatalf("%s: %v", dir, err)
+		}
+
+		latest = append(latest, len(m))
+	}
+
+	// Every dialect must describe the same schema versions.
+	for _, n := range latest[1:] {
+		assert.Equal(t, n, latest[0])
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		valid bool
+	}{
+		{
+			name: "Valid",
+			files: fstest.MapFS{
+				"d/0001_a.up.sql":   {Data: []byte("up")},
+				"d/0001_a.down.sql": {Data: []byte("down")},
+				"d/0002_b.up.sql":   {Data: []byte("up")},
+				"d/0002_b.down.sql": {Data: []byte("down")},
+			},
+			valid: true,
+		},
+		{
+			name: "Missing down",
+			files: fstest.MapFS{
+				"d/0001_a.up.sql": {Data: []byte("up")},
+			},
+		},
+		{
+			name: "Gap",
+			files: fstest.MapFS{
+				"d/0001_a.up.sql":   {Data: []byte("up")},
+				"d/0001_a.down.sql": {Data: []byte("down")},
+				"d/0003_c.up.sql":   {Data: []byte("up")},
+				"d/0003_c.down.sql": {Data: []byte("down")},
+			},
+		},
+		{
+			name: "Bad name",
+			files: fstest.MapFS{
+				"d/first.up.sql": {Data: []byte("up")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(tt.files, "d")
+			assert.Equal(t, err == nil, tt.valid)
+		})
+	}
+}
+
+func TestMigratorSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db")+"?_time_format=sqlite&_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m, err := New(db, models.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version := func() int {
+		t.Helper()
+
+		v, err := m.Version()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return v
+	}
+
+	assert.Equal(t, version(), 0)
+
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, version(), m.Latest())
+
+	if _, err := db.Exec("SELECT id, title FROM snippets"); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(statuses), m.Latest())
+	for _, s := range statuses {
+		assert.Equal(t, s.Applied, true)
+	}
+
+	if err := m.Down(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, version(), m.Latest()-1)
+
+	if err := m.To(0); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, version(), 0)
+
+	if _, err := db.Exec("SELECT id FROM snippets"); err == nil {
+		t.Error("snippets table still exists after migrating to 0")
+	}
+
+	if err := m.To(m.Latest() + 1); err == nil {
+		t.Error("migrating past the latest version succeeded")
+	}
+
+	// The down migrations must leave the database clean enough to go
+	// back up again.
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, version(), m.Latest())
+}
@@ -0,0 +1,11 @@
+CREATE TABLE snippets (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    CONSTRAINT fk_snippets_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX idx_snippets_created ON snippets (created);
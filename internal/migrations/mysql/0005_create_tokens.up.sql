@@ -0,0 +1,12 @@
+CREATE TABLE tokens (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    scopes VARCHAR(255) NOT NULL,
+    hash BINARY(32) NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    last_used DATETIME,
+    CONSTRAINT tokens_uc_hash UNIQUE (hash),
+    CONSTRAINT fk_tokens_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
@@ -0,0 +1 @@
+ALTER TABLE snippets ADD FULLTEXT INDEX idx_snippets_search (title, content);
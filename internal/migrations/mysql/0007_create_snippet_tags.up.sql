@@ -0,0 +1,8 @@
+CREATE TABLE snippet_tags (
+    snippet_id INTEGER NOT NULL,
+    tag VARCHAR(30) NOT NULL,
+    PRIMARY KEY (snippet_id, tag),
+    CONSTRAINT fk_snippet_tags_snippet FOREIGN KEY (snippet_id) REFERENCES snippets (id)
+);
+
+CREATE INDEX idx_snippet_tags_tag ON snippet_tags (tag);
@@ -0,0 +1,5 @@
+ALTER TABLE snippets DROP INDEX idx_snippets_slug;
+
+ALTER TABLE snippets DROP COLUMN slug;
+
+ALTER TABLE snippets DROP COLUMN visibility;
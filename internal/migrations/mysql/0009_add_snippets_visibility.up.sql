@@ -0,0 +1,7 @@
+ALTER TABLE snippets ADD COLUMN visibility VARCHAR(10) NOT NULL DEFAULT 'public';
+
+ALTER TABLE snippets ADD COLUMN slug VARCHAR(32) NOT NULL DEFAULT '';
+
+UPDATE snippets SET slug = LOWER(HEX(RANDOM_BYTES(16)));
+
+CREATE UNIQUE INDEX idx_snippets_slug ON snippets (slug);
@@ -0,0 +1,11 @@
+CREATE TABLE tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    name VARCHAR(100) NOT NULL,
+    scopes VARCHAR(255) NOT NULL,
+    hash BYTEA NOT NULL,
+    created TIMESTAMPTZ NOT NULL,
+    expires TIMESTAMPTZ NOT NULL,
+    last_used TIMESTAMPTZ,
+    CONSTRAINT tokens_uc_hash UNIQUE (hash)
+);
@@ -0,0 +1,3 @@
+DROP INDEX idx_snippets_search;
+
+ALTER TABLE snippets DROP COLUMN search_vector;
@@ -0,0 +1,7 @@
+CREATE TABLE snippet_tags (
+    snippet_id INTEGER NOT NULL REFERENCES snippets (id),
+    tag VARCHAR(30) NOT NULL,
+    PRIMARY KEY (snippet_id, tag)
+);
+
+CREATE INDEX idx_snippet_tags_tag ON snippet_tags (tag);
@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN language VARCHAR(50) NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+CREATE TABLE snippet_revisions (
+    snippet_id INTEGER NOT NULL REFERENCES snippets (id),
+    revision INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (snippet_id, revision)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    name TEXT NOT NULL,
+    scopes TEXT NOT NULL,
+    hash BLOB NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    last_used DATETIME,
+    CONSTRAINT tokens_uc_hash UNIQUE (hash)
+);
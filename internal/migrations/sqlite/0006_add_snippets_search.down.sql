@@ -0,0 +1 @@
+DROP TABLE snippets_fts;
@@ -0,0 +1,3 @@
+CREATE VIRTUAL TABLE snippets_fts USING fts5 (title, content);
+
+INSERT INTO snippets_fts (rowid, title, content) SELECT id, title, content FROM snippets;
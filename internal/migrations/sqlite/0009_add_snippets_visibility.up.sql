@@ -0,0 +1,7 @@
+ALTER TABLE snippets ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public';
+
+ALTER TABLE snippets ADD COLUMN slug TEXT NOT NULL DEFAULT '';
+
+UPDATE snippets SET slug = LOWER(HEX(RANDOMBLOB(16)));
+
+CREATE UNIQUE INDEX idx_snippets_slug ON snippets (slug);
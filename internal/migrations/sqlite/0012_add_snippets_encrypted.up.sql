@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN encrypted INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// Dialect identifies the SQL database the models talk to. The models are
+// written with MySQL-style "?" placeholders, and the dialect papers over the
+// few places where the databases differ. The zero value is MySQL.
+type Dialect int
+
+const (
+	MySQL Dialect = iota
+	SQLite
+	Postgres
+)
+
+// Querier is satisfied by both *sql.DB and *sql.Tx.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Rebind rewrites the "?" placeholders in query into the form the dialect
+// expects. Queries must not contain literal question marks.
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+
+	var (
+		sb strings.Builder
+		n  int
+	)
+
+	for _, r := range query {
+		if r == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
+
+// InsertID runs an INSERT statement and returns the generated "id" column.
+// Postgres has no LastInsertId, so the ID is read back with RETURNING.
+func (d Dialect) InsertID(ctx context.Context, q Querier, query string, args ...any) (int, error) {
+	if d == Postgres {
+		var id int
+		err := q.QueryRowContext(ctx, d.Rebind(query)+" RETURNING id", args...).Scan(&id)
+
+		return id, err
+	}
+
+	result, err := q.ExecContext(ctx, d.Rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/yousifsabah0/snippets/internal/assert"
+)
+
+func TestRebind(t *testing.T) {
+	query := "SELECT id FROM snippets WHERE id = ? AND expires > ? LIMIT ?"
+
+	assert.Equal(t, MySQL.Rebind(query), query)
+	assert.Equal(t, SQLite.Rebind(query), query)
+	assert.Equal(t, Postgres.Rebind(query), "SELECT id FROM snippets WHERE id = $1 AND expires > $2 LIMIT $3")
+}
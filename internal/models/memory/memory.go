@@ -0,0 +1,50 @@
+// Package memory implements the model stores in process memory. Nothing is
+// persisted, which makes it a good fit for tests and demos.
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/models/snippets"
+	"github.com/yousifsabah0/snippets/internal/models/tokens"
+	"github.com/yousifsabah0/snippets/internal/models/users"
+	"github.com/yousifsabah0/snippets/internal/search"
+)
+
+// Store plays the part of the database for the memory models. A single Store
+// is shared by all of them so that, for example, snippets can be joined with
+// the name of their author.
+type Store struct {
+	mu sync.RWMutex
+
+	// now is the clock used for created and expiry times. Tests replace it
+	// to move time forward.
+	now func() time.Time
+
+	snippets  map[int]snippets.Snippet
+	revisions map[int][]snippets.Revision
+	users     map[int]users.User
+	tokens    map[int]tokens.Token
+	// index is the full-text index of the snippets.
+	index *search.Index
+
+	lastSnippetID int
+	lastUserID    int
+	lastTokenID   int
+}
+
+func NewStore() *Store {
+	return &Store{
+		now:       time.Now,
+		snippets:  map[int]snippets.Snippet{},
+		revisions: map[int][]snippets.Revision{},
+		users:     map[int]users.User{},
+		tokens:    map[int]tokens.Token{},
+		index:     search.NewIndex(),
+	}
+}
+
+func (s *Store) utcNow() time.Time {
+	return s.now().UTC()
+}
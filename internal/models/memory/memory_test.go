@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/assert"
+	"github.com/yousifsabah0/snippets/internal/models"
+	"github.com/yousifsabah0/snippets/internal/models/snippets"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestSnippetExpiry(t *testing.T) {
+	store := NewStore()
+	now := time.Date(2025, 7, 5, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	m := &SnippetModel{Store: store}
+
+	id, err := m.Insert(t.Context(), 1, snippets.Draft{Title: "Title", Content: "Content", Visibility: snippets.Public, Expires: now.AddDate(0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snippet, err := m.Get(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, snippet.Expires, now.AddDate(0, 0, 1))
+
+	now = now.AddDate(0, 0, 2)
+
+	_, err = m.Get(t.Context(), id)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+	latest, err := m.Latest(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(latest), 0)
+}
+
+func TestUserModel(t *testing.T) {
+	m := &UserModel{Store: NewStore(), Cost: bcrypt.MinCost}
+
+	if err := m.Insert(t.Context(), "Alice", "alice@example.com", "pa55word"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := m.Insert(t.Context(), "Alice Again", "alice@example.com", "pa55word")
+	assert.Equal(t, errors.Is(err, models.ErrDuplicateEmail), true)
+
+	id, err := m.Authenticate(t.Context(), "alice@example.com", "pa55word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, id, 1)
+
+	_, err = m.Authenticate(t.Context(), "alice@example.com", "wrong")
+	assert.Equal(t, errors.Is(err, models.ErrInvalidCredentials), true)
+
+	_, err = m.Authenticate(t.Context(), "bob@example.com", "pa55word")
+	assert.Equal(t, errors.Is(err, models.ErrInvalidCredentials), true)
+
+	exists, err := m.Exists(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, exists, true)
+}
@@ -0,0 +1,374 @@
+package memory
+
+import (
+	"context"
+	"crypto/rand"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/models"
+	"github.com/yousifsabah0/snippets/internal/models/snippets"
+	"github.com/yousifsabah0/snippets/internal/search"
+)
+
+type SnippetModel struct {
+	Store *Store
+	// Cost is the bcrypt cost used to hash snippet passwords,
+	// snippets.DefaultCost if zero.
+	Cost int
+}
+
+var _ snippets.SnippetStore = (*SnippetModel)(nil)
+
+func (m *SnippetModel) Insert(ctx context.Context, userID int, draft snippets.Draft) (int, error) {
+	passwordHash, _, err := draft.PasswordHash(m.Cost)
+	if err != nil {
+		return 0, err
+	}
+
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+
+	now := m.Store.utcNow()
+
+	m.Store.lastSnippetID++
+	snippet := snippets.Snippet{
+		ID:           m.Store.lastSnippetID,
+		UserID:       userID,
+		Title:        draft.Title,
+		Content:      draft.Content,
+		Language:     draft.Language,
+		Tags:         snippets.SortTags(draft.Tags),
+		Visibility:   draft.Visibility,
+		Encrypted:    draft.Encrypted,
+		Slug:         rand.Text(),
+		MaxViews:     draft.MaxViews,
+		PasswordHash: passwordHash,
+		Created:      now,
+		Expires:      draft.Expires.UTC(),
+	}
+
+	m.Store.snippets[snippet.ID] = snippet
+	m.Store.addRevision(snippet.ID, draft.Title, draft.Content, now)
+	m.Store.index.Add(snippet.ID, draft.Title, draft.Content)
+
+	return snippet.ID, nil
+}
+
+func (m *SnippetModel) Get(ctx context.Context, id int) (snippets.Snippet, error) {
+	m.Store.mu.RLock()
+	defer m.Store.mu.RUnlock()
+
+	snippet, ok := m.Store.liveSnippet(id)
+	if !ok {
+		return snippets.Snippet{}, models.ErrNoRecord
+	}
+
+	return snippet, nil
+}
+
+func (m *SnippetModel) GetBySlug(ctx context.Context, slug string) (snippets.Snippet, error) {
+	m.Store.mu.RLock()
+	defer m.Store.mu.RUnlock()
+
+	for id, snippet := range m.Store.snippets {
+		if snippet.Slug == slug {
+			if snippet, ok := m.Store.liveSnippet(id); ok {
+				return snippet, nil
+			}
+		}
+	}
+
+	return snippets.Snippet{}, models.ErrNoRecord
+}
+
+func (m *SnippetModel) Update(ctx context.Context, id int, draft snippets.Draft) error {
+	passwordHash, changed, err := draft.PasswordHash(m.Cost)
+	if err != nil {
+		return err
+	}
+
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+
+	snippet, ok := m.Store.snippets[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+
+	now := m.Store.utcNow()
+
+	if snippet.Visibility != snippets.Unlisted {
+		snippet.Slug = rand.Text()
+	}
+
+	snippet.Title = draft.Title
+	snippet.Content = draft.Content
+	snippet.Language = draft.Language
+	snippet.Tags = snippets.SortTags(draft.Tags)
+	snippet.Visibility = draft.Visibility
+	snippet.Encrypted = draft.Encrypted
+	if snippet.MaxViews != draft.MaxViews {
+		snippet.Views = 0
+	}
+	snippet.MaxViews = draft.MaxViews
+	if !draft.Expires.IsZero() {
+		snippet.Expires = draft.Expires.UTC()
+	}
+	if changed {
+		snippet.PasswordHash = passwordHash
+	}
+
+	m.Store.snippets[id] = snippet
+	if draft.Encrypted {
+		delete(m.Store.revisions, id)
+	}
+	m.Store.addRevision(id, draft.Title, draft.Content, now)
+	m.Store.index.Add(id, draft.Title, draft.Content)
+
+	return nil
+}
+
+func (m *SnippetModel) SetExpiry(ctx context.Context, id int, expires time.Time) error {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+
+	if _, ok := m.Store.liveSnippet(id); !ok {
+		return models.ErrNoRecord
+	}
+
+	snippet := m.Store.snippets[id]
+	snippet.Expires = expires.UTC()
+	m.Store.snippets[id] = snippet
+
+	return nil
+}
+
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+
+	if _, ok := m.Store.snippets[id]; !ok {
+		return models.ErrNoRecord
+	}
+
+	m.Store.deleteSnippet(id)
+
+	return nil
+}
+
+func (m *SnippetModel) View(ctx context.Context, id int) (snippets.Snippet, error) {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+
+	snippet, ok := m.Store.liveSnippet(id)
+	if !ok || snippet.ViewsLeft() == 0 {
+		return snippets.Snippet{}, models.ErrNoRecord
+	}
+
+	snippet.Views++
+
+	stored := m.Store.snippets[id]
+	stored.Views = snippet.Views
+	m.Store.snippets[id] = stored
+
+	if snippet.ViewsLeft() == 0 {
+		m.Store.deleteSnippet(id)
+	}
+
+	return snippet, nil
+}
+
+func (m *SnippetModel) Latest(ctx context.Context) ([]snippets.Snippet, error) {
+	return m.Recent(ctx, "", 10, 0)
+}
+
+func (m *SnippetModel) Recent(ctx context.Context, tag string, limit, offset int) ([]snippets.Snippet, error) {
+	m.Store.mu.RLock()
+	defer m.Store.mu.RUnlock()
+
+	return paginate(m.Store.liveSnippets(func(s snippets.Snippet) bool { return listed(s) && hasTag(s, tag) }), limit, offset), nil
+}
+
+func (m *SnippetModel) List(ctx context.Context, opts snippets.ListOptions) (snippets.Page, error) {
+	cursor, backwards, err := opts.Normalize()
+	if err != nil {
+		return snippets.Page{}, err
+	}
+
+	m.Store.mu.RLock()
+	defer m.Store.mu.RUnlock()
+
+	matches := m.Store.liveSnippets(func(s snippets.Snippet) bool {
+		return listed(s) && (opts.Author == "" || s.Author == opts.Author) && hasTag(s, opts.Tag) &&
+			(opts.From.IsZero() || !s.Created.Before(opts.From)) &&
+			(opts.To.IsZero() || s.Created.Before(opts.To))
+	})
+
+	slices.SortFunc(matches, func(a, b snippets.Snippet) int {
+		return opts.Compare(snippets.CursorFor(a), snippets.CursorFor(b))
+	})
+
+	// Going backwards, walk from the cursor towards the start of the
+	// listing, as the SQL stores do.
+	if backwards {
+		slices.Reverse(matches)
+	}
+
+	var fetched []snippets.Snippet
+	for _, s := range matches {
+		if cursor != nil {
+			c := opts.Compare(snippets.CursorFor(s), *cursor)
+			if (!backwards && c <= 0) || (backwards && c >= 0) {
+				continue
+			}
+		}
+
+		fetched = append(fetched, s)
+		if len(fetched) > opts.Limit {
+			break
+		}
+	}
+
+	return opts.NewPage(fetched, cursor, backwards), nil
+}
+
+func (m *SnippetModel) Search(ctx context.Context, query, tag string, limit int) ([]snippets.SearchResult, error) {
+	m.Store.mu.RLock()
+	defer m.Store.mu.RUnlock()
+
+	var results []snippets.SearchResult
+	for _, hit := range m.Store.index.Search(search.Terms(query)) {
+		if len(results) == limit {
+			break
+		}
+
+		snippet, ok := m.Store.liveSnippet(hit.ID)
+		if !ok || !listed(snippet) || snippet.Protected() || snippet.Encrypted || !hasTag(snippet, tag) {
+			continue
+		}
+
+		results = append(results, snippets.SearchResult{Snippet: snippet, Score: hit.Score})
+	}
+
+	return results, nil
+}
+
+func (m *SnippetModel) Tags(ctx context.Context) ([]snippets.TagCount, error) {
+	m.Store.mu.RLock()
+	defer m.Store.mu.RUnlock()
+
+	counts := map[string]int{}
+	for _, snippet := range m.Store.liveSnippets(listed) {
+		for _, tag := range snippet.Tags {
+			counts[tag]++
+		}
+	}
+
+	var tags []snippets.TagCount
+	for _, tag := range slices.Sorted(maps.Keys(counts)) {
+		tags = append(tags, snippets.TagCount{Tag: tag, Count: counts[tag]})
+	}
+
+	return tags, nil
+}
+
+func (m *SnippetModel) ByUser(ctx context.Context, userID int) ([]snippets.Snippet, error) {
+	m.Store.mu.RLock()
+	defer m.Store.mu.RUnlock()
+
+	return m.Store.liveSnippets(func(s snippets.Snippet) bool { return s.UserID == userID }), nil
+}
+
+func (m *SnippetModel) Revisions(ctx context.Context, id int) ([]snippets.Revision, error) {
+	m.Store.mu.RLock()
+	defer m.Store.mu.RUnlock()
+
+	revisions := slices.Clone(m.Store.revisions[id])
+	slices.Reverse(revisions)
+
+	return revisions, nil
+}
+
+func (m *SnippetModel) Revision(ctx context.Context, id, n int) (snippets.Revision, error) {
+	m.Store.mu.RLock()
+	defer m.Store.mu.RUnlock()
+
+	revisions := m.Store.revisions[id]
+	if n < 1 || n > len(revisions) {
+		return snippets.Revision{}, models.ErrNoRecord
+	}
+
+	return revisions[n-1], nil
+}
+
+// deleteSnippet must be called with the write lock held.
+func (s *Store) deleteSnippet(id int) {
+	delete(s.snippets, id)
+	delete(s.revisions, id)
+	s.index.Remove(id)
+}
+
+// addRevision must be called with the write lock held.
+func (s *Store) addRevision(id int, title, content string, created time.Time) {
+	s.revisions[id] = append(s.revisions[id], snippets.Revision{
+		SnippetID: id,
+		Number:    len(s.revisions[id]) + 1,
+		Title:     title,
+		Content:   content,
+		Created:   created,
+	})
+}
+
+// liveSnippet returns a non-expired snippet joined with the name of its
+// author. It must be called with the lock held.
+func (s *Store) liveSnippet(id int) (snippets.Snippet, bool) {
+	snippet, ok := s.snippets[id]
+	if !ok || !snippet.Expires.After(s.utcNow()) {
+		return snippets.Snippet{}, false
+	}
+
+	snippet.Author = s.users[snippet.UserID].Name
+	// Callers must not be able to change the stored tags.
+	snippet.Tags = slices.Clone(snippet.Tags)
+
+	return snippet, true
+}
+
+// liveSnippets returns the non-expired snippets matching keep, newest first,
+// without the content of protected ones. It must be called with the lock
+// held.
+func (s *Store) liveSnippets(keep func(snippets.Snippet) bool) []snippets.Snippet {
+	var result []snippets.Snippet
+	for id := range s.snippets {
+		snippet, ok := s.liveSnippet(id)
+		if ok && keep(snippet) {
+			snippets.Redact(&snippet)
+			result = append(result, snippet)
+		}
+	}
+
+	slices.SortFunc(result, func(a, b snippets.Snippet) int { return b.ID - a.ID })
+
+	return result
+}
+
+// listed reports whether a snippet is listed and searchable.
+func listed(s snippets.Snippet) bool {
+	return s.Visibility == snippets.Public && s.MaxViews == 0
+}
+
+// hasTag reports whether a snippet carries tag, which is always true of the
+// empty tag.
+func hasTag(s snippets.Snippet, tag string) bool {
+	return tag == "" || slices.Contains(s.Tags, tag)
+}
+
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+
+	return items[offset:min(offset+limit, len(items))]
+}
@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/models"
+	"github.com/yousifsabah0/snippets/internal/models/tokens"
+)
+
+type TokenModel struct {
+	Store *Store
+}
+
+var _ tokens.TokenStore = (*TokenModel)(nil)
+
+func (m *TokenModel) New(ctx context.Context, userID int, name string, scopes []string, ttl time.Duration) (tokens.Token, error) {
+	token, err := tokens.Generate(userID, name, slices.Clone(scopes), ttl)
+	if err != nil {
+		return tokens.Token{}, err
+	}
+
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+
+	token.Created = m.Store.utcNow()
+	token.Expires = token.Created.Add(ttl)
+
+	m.Store.lastTokenID++
+	token.ID = m.Store.lastTokenID
+
+	stored := token
+	stored.Plaintext = ""
+	m.Store.tokens[token.ID] = stored
+
+	return token, nil
+}
+
+func (m *TokenModel) ForUser(ctx context.Context, userID int) ([]tokens.Token, error) {
+	m.Store.mu.RLock()
+	defer m.Store.mu.RUnlock()
+
+	var result []tokens.Token
+	for _, token := range m.Store.tokens {
+		if token.UserID == userID {
+			token.Scopes = slices.Clone(token.Scopes)
+			result = append(result, token)
+		}
+	}
+
+	slices.SortFunc(result, func(a, b tokens.Token) int { return b.ID - a.ID })
+
+	return result, nil
+}
+
+func (m *TokenModel) Revoke(ctx context.Context, userID, id int) error {
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+
+	token, ok := m.Store.tokens[id]
+	if !ok || token.UserID != userID {
+		return models.ErrNoRecord
+	}
+
+	delete(m.Store.tokens, id)
+
+	return nil
+}
+
+func (m *TokenModel) Authenticate(ctx context.Context, plaintext string) (tokens.Token, error) {
+	hash := tokens.Hash(plaintext)
+
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+
+	now := m.Store.utcNow()
+	for id, token := range m.Store.tokens {
+		if bytes.Equal(token.Hash, hash) && token.Expires.After(now) {
+			token.LastUsed = now
+			m.Store.tokens[id] = token
+
+			token.Scopes = slices.Clone(token.Scopes)
+			return token, nil
+		}
+	}
+
+	return tokens.Token{}, models.ErrInvalidCredentials
+}
@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yousifsabah0/snippets/internal/models"
+	"github.com/yousifsabah0/snippets/internal/models/users"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type UserModel struct {
+	Store *Store
+
+	// Cost is the bcrypt cost used to hash passwords, users.DefaultCost if
+	// zero; tests lower it to stay fast.
+	Cost int
+}
+
+var _ users.UserStore = (*UserModel)(nil)
+
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) error {
+	cost := m.Cost
+	if cost == 0 {
+		cost = users.DefaultCost
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return err
+	}
+
+	m.Store.mu.Lock()
+	defer m.Store.mu.Unlock()
+
+	for _, user := range m.Store.users {
+		if user.Email == email {
+			return models.ErrDuplicateEmail
+		}
+	}
+
+	m.Store.lastUserID++
+	m.Store.users[m.Store.lastUserID] = users.User{
+		ID:             m.Store.lastUserID,
+		Name:           name,
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Created:        m.Store.utcNow(),
+	}
+
+	return nil
+}
+
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	m.Store.mu.RLock()
+	var user users.User
+	for _, u := range m.Store.users {
+		if u.Email == email {
+			user = u
+			break
+		}
+	}
+	m.Store.mu.RUnlock()
+
+	if user.ID == 0 {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword(user.HashedPassword, []byte(password)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, models.ErrInvalidCredentials
+		}
+
+		return 0, err
+	}
+
+	return user.ID, nil
+}
+
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
+	m.Store.mu.RLock()
+	defer m.Store.mu.RUnlock()
+
+	_, ok := m.Store.users[id]
+
+	return ok, nil
+}
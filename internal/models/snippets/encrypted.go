@@ -0,0 +1,23 @@
+package snippets
+
+import "encoding/base64"
+
+// The content of an encrypted snippet is sealed with AES-GCM in the browser,
+// which puts the nonce in front of it and appends the tag.
+const (
+	nonceSize = 12
+	tagSize   = 16
+)
+
+// ValidCiphertext reports whether content is well-formed as the content of
+// an encrypted snippet: the standard base64 encoding of a nonce, the sealed
+// content and its tag. The server never has the key, so that is all it can
+// check.
+func ValidCiphertext(content string) bool {
+	b, err := base64.StdEncoding.Strict().DecodeString(content)
+	return err == nil && len(b) > nonceSize+tagSize
+}
+
+// plaintextFilter is the condition restricting a query on snippets s to
+// those that are not encrypted, the only ones whose content can be searched.
+const plaintextFilter = "s.encrypted = FALSE"
@@ -0,0 +1,48 @@
+package snippets
+
+import (
+	"context"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/models"
+)
+
+// Never is the expiry of snippets that are kept until they are deleted. It
+// is a date rather than a NULL, so that every query on unexpired snippets
+// still only has to compare expires, and the latest one all backends store.
+var Never = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// NeverExpires reports whether the snippet is kept until it is deleted.
+func (s Snippet) NeverExpires() bool {
+	return !s.Expires.Before(Never)
+}
+
+// TimeLeft returns how long the snippet has left at now before it expires.
+func (s Snippet) TimeLeft(now time.Time) time.Duration {
+	return max(s.Expires.Sub(now), 0)
+}
+
+// SetExpiry changes when a specific snippet expires, which can extend or
+// shorten its life. It returns models.ErrNoRecord for snippets that have
+// already expired.
+func (m *SnippetModel) SetExpiry(ctx context.Context, id int, expires time.Time) error {
+	// On MySQL, giving a snippet the expiry it already has only counts as a
+	// row because openDB connects with clientFoundRows.
+	stmt := `UPDATE snippets SET expires = ? WHERE id = ? AND expires > ?`
+
+	result, err := m.DB.ExecContext(ctx, m.Dialect.Rebind(stmt), expires.UTC(), id, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,261 @@
+package snippets
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sort is the order a listing is returned in.
+type Sort string
+
+const (
+	SortNewest   Sort = "newest"
+	SortOldest   Sort = "oldest"
+	SortExpiring Sort = "expiring"
+)
+
+// Sorts lists every supported order, the default first.
+var Sorts = []Sort{SortNewest, SortOldest, SortExpiring}
+
+// DefaultListLimit is the page size used when ListOptions.Limit is zero.
+const DefaultListLimit = 20
+
+// ErrInvalidCursor is returned for a cursor that was not produced by a
+// listing in the same order.
+var ErrInvalidCursor = errors.New("models: invalid cursor")
+
+// ListOptions filters and orders a listing of non-expired public snippets.
+type ListOptions struct {
+	Sort Sort
+	// Author restricts the listing to snippets by users of that name.
+	Author string
+	// Tag restricts the listing to snippets carrying that tag.
+	Tag string
+	// From and To restrict the listing to snippets created in [From, To).
+	// Either may be zero to leave that end open.
+	From, To time.Time
+
+	Limit int
+	// After and Before are cursors from a previous Page, returning the
+	// snippets that follow or precede it. At most one may be set.
+	After, Before string
+}
+
+// Page is one page of a listing. Next and Prev are the cursors for the
+// neighbouring pages, empty when there are none.
+type Page struct {
+	Snippets []Snippet
+	Next     string
+	Prev     string
+}
+
+// Cursor is a position in a listing: the sort key of the snippet it was
+// taken from. Expires is only used when sorting by expiry.
+type Cursor struct {
+	ID      int
+	Expires time.Time
+}
+
+// Normalize fills in the defaults and checks the options, returning the
+// decoded cursor and whether it is a Before cursor.
+func (o *ListOptions) Normalize() (cursor *Cursor, backwards bool, err error) {
+	if o.Sort == "" {
+		o.Sort = SortNewest
+	}
+	if !slices.Contains(Sorts, o.Sort) {
+		return nil, false, fmt.Errorf("models: unknown sort %q", o.Sort)
+	}
+
+	if o.Limit <= 0 {
+		o.Limit = DefaultListLimit
+	}
+
+	if o.After != "" && o.Before != "" {
+		return nil, false, errors.New("models: both After and Before cursors given")
+	}
+
+	raw := o.After
+	if o.Before != "" {
+		raw, backwards = o.Before, true
+	}
+
+	if raw == "" {
+		return nil, false, nil
+	}
+
+	c, err := o.parseCursor(raw)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &c, backwards, nil
+}
+
+// Compare orders two positions the way the listing returns them.
+func (o ListOptions) Compare(a, b Cursor) int {
+	switch o.Sort {
+	case SortOldest:
+		return a.ID - b.ID
+	case SortExpiring:
+		if c := a.Expires.Compare(b.Expires); c != 0 {
+			return c
+		}
+		return a.ID - b.ID
+	default:
+		return b.ID - a.ID
+	}
+}
+
+// CursorFor returns the position of a snippet in a listing.
+func CursorFor(s Snippet) Cursor {
+	return Cursor{ID: s.ID, Expires: s.Expires}
+}
+
+// NewPage builds a page from up to Limit+1 snippets fetched after (or, going
+// backwards, before and in reverse order) the cursor. The extra snippet only
+// tells whether there is another page.
+func (o ListOptions) NewPage(fetched []Snippet, cursor *Cursor, backwards bool) Page {
+	more := len(fetched) > o.Limit
+	if more {
+		fetched = fetched[:o.Limit]
+	}
+
+	if backwards {
+		slices.Reverse(fetched)
+	}
+
+	page := Page{Snippets: fetched}
+	if len(fetched) == 0 {
+		return page
+	}
+
+	first, last := fetched[0], fetched[len(fetched)-1]
+
+	// Paging forwards means there is something before the cursor, and
+	// paging backwards that there is something after it.
+	if (backwards && more) || (!backwards && cursor != nil) {
+		page.Prev = o.formatCursor(CursorFor(first))
+	}
+	if (!backwards && more) || (backwards && cursor != nil) {
+		page.Next = o.formatCursor(CursorFor(last))
+	}
+
+	return page
+}
+
+// formatCursor encodes a position opaquely, so that clients pass cursors
+// back without relying on what is inside them.
+func (o ListOptions) formatCursor(c Cursor) string {
+	s := strconv.Itoa(c.ID)
+	if o.Sort == SortExpiring {
+		s = strconv.FormatInt(c.Expires.UnixNano(), 10) + "." + s
+	}
+
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+func (o ListOptions) parseCursor(s string) (Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var c Cursor
+
+	id := string(b)
+	if o.Sort == SortExpiring {
+		nanos, rest, ok := strings.Cut(id, ".")
+		if !ok {
+			return Cursor{}, ErrInvalidCursor
+		}
+
+		n, err := strconv.ParseInt(nanos, 10, 64)
+		if err != nil {
+			return Cursor{}, ErrInvalidCursor
+		}
+
+		c.Expires, id = time.Unix(0, n).UTC(), rest
+	}
+
+	c.ID, err = strconv.Atoi(id)
+	if err != nil || c.ID < 1 {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// List returns one page of non-expired snippets matching the options, using
+// the sort key of the last snippet seen rather than an offset so that pages
+// stay stable while snippets are added.
+func (m *SnippetModel) List(ctx context.Context, opts ListOptions) (Page, error) {
+	cursor, backwards, err := opts.Normalize()
+	if err != nil {
+		return Page{}, err
+	}
+
+	where := []string{"s.expires > ?", listedFilter}
+	args := []any{time.Now().UTC()}
+
+	if opts.Author != "" {
+		where = append(where, "u.name = ?")
+		args = append(args, opts.Author)
+	}
+	if opts.Tag != "" {
+		where = append(where, tagFilter)
+		args = append(args, opts.Tag)
+	}
+	if !opts.From.IsZero() {
+		where = append(where, "s.created >= ?")
+		args = append(args, opts.From.UTC())
+	}
+	if !opts.To.IsZero() {
+		where = append(where, "s.created < ?")
+		args = append(args, opts.To.UTC())
+	}
+
+	// Going backwards the order is flipped, so the rows nearest the cursor
+	// come first, and NewPage reverses them again.
+	desc := opts.Sort == SortNewest
+	if backwards {
+		desc = !desc
+	}
+
+	op, dir := ">", "ASC"
+	if desc {
+		op, dir = "<", "DESC"
+	}
+
+	order := "s.id " + dir
+	if opts.Sort == SortExpiring {
+		order = "s.expires " + dir + ", s.id " + dir
+	}
+
+	if cursor != nil {
+		if opts.Sort == SortExpiring {
+			where = append(where, "(s.expires "+op+" ? OR (s.expires = ? AND s.id "+op+" ?))")
+			args = append(args, cursor.Expires, cursor.Expires, cursor.ID)
+		} else {
+			where = append(where, "s.id "+op+" ?")
+			args = append(args, cursor.ID)
+		}
+	}
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets s
+	INNER JOIN users u ON u.id = s.user_id
+	WHERE ` + strings.Join(where, " AND ") + ` ORDER BY ` + order + ` LIMIT ?`
+	args = append(args, opts.Limit+1)
+
+	fetched, err := m.query(ctx, stmt, args...)
+	if err != nil {
+		return Page{}, err
+	}
+
+	return opts.NewPage(fetched, cursor, backwards), nil
+}
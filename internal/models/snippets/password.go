@@ -0,0 +1,61 @@
+package snippets
+
+import (
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultCost is the bcrypt cost used to hash snippet passwords unless a
+// model is given another.
+const DefaultCost = 12
+
+// Protected reports whether the snippet can only be read with its password.
+func (s Snippet) Protected() bool {
+	return s.PasswordHash != ""
+}
+
+// CheckPassword reports whether password is the password of a protected
+// snippet.
+func (s Snippet) CheckPassword(password string) bool {
+	return s.Protected() && bcrypt.CompareHashAndPassword([]byte(s.PasswordHash), []byte(password)) == nil
+}
+
+// hashPassword hashes a snippet password with bcrypt at cost, or DefaultCost
+// if it is zero.
+func hashPassword(password string, cost int) (string, error) {
+	if cost == 0 {
+		cost = DefaultCost
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// Redact blanks the content of a protected snippet, as every listing does.
+func Redact(s *Snippet) {
+	if s.Protected() {
+		s.Content = ""
+	}
+}
+
+// unprotectedFilter is the condition restricting a query on snippets s to
+// those without a password. Protected snippets are never searched, as the
+// results would give away what their content contains.
+const unprotectedFilter = "s.password_hash = ''"
+
+// PasswordHash returns the password hash to store for a draft, and whether
+// it changes the stored one at all.
+func (d Draft) PasswordHash(cost int) (string, bool, error) {
+	switch {
+	case d.Password != "":
+		hash, err := hashPassword(d.Password, cost)
+		return hash, true, err
+	case d.RemovePassword:
+		return "", true, nil
+	default:
+		return "", false, nil
+	}
+}
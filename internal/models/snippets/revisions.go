@@ -0,0 +1,85 @@
+package snippets
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/models"
+)
+
+// Revision is one saved version of a snippet. Revisions are numbered from 1
+// per snippet, and the highest number is the current version.
+type Revision struct {
+	SnippetID int
+	Number    int
+	Title     string
+	Content   string
+	Created   time.Time
+}
+
+// insertRevision records title and content as the next revision of a
+// snippet. It runs inside the transaction that changed the snippet, whose
+// row lock keeps concurrent edits from picking the same number.
+func (m *SnippetModel) insertRevision(ctx context.Context, tx *sql.Tx, snippetID int, title, content string, created time.Time) error {
+	var n int
+
+	stmt := `SELECT COALESCE(MAX(revision), 0) + 1 FROM snippet_revisions WHERE snippet_id = ?`
+	if err := tx.QueryRowContext(ctx, m.Dialect.Rebind(stmt), snippetID).Scan(&n); err != nil {
+		return err
+	}
+
+	stmt = `INSERT INTO snippet_revisions (snippet_id, revision, title, content, created) VALUES (?, ?, ?, ?, ?)`
+	_, err := tx.ExecContext(ctx, m.Dialect.Rebind(stmt), snippetID, n, title, content, created)
+
+	return err
+}
+
+// This will return every revision of a specific snippet, newest first.
+func (m *SnippetModel) Revisions(ctx context.Context, id int) ([]Revision, error) {
+	var revisions []Revision
+
+	stmt := `SELECT snippet_id, revision, title, content, created FROM snippet_revisions
+	WHERE snippet_id = ? ORDER BY revision DESC`
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.Rebind(stmt), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var revision Revision
+		if err := rows.Scan(&revision.SnippetID, &revision.Number, &revision.Title, &revision.Content, &revision.Created); err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// This will return revision n of a specific snippet.
+func (m *SnippetModel) Revision(ctx context.Context, id, n int) (Revision, error) {
+	var revision Revision
+
+	stmt := `SELECT snippet_id, revision, title, content, created FROM snippet_revisions
+	WHERE snippet_id = ? AND revision = ?`
+
+	row := m.DB.QueryRowContext(ctx, m.Dialect.Rebind(stmt), id, n)
+	if err := row.Scan(&revision.SnippetID, &revision.Number, &revision.Title, &revision.Content, &revision.Created); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Revision{}, models.ErrNoRecord
+		}
+
+		return Revision{}, err
+	}
+
+	return revision, nil
+}
@@ -0,0 +1,137 @@
+package snippets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/models"
+	"github.com/yousifsabah0/snippets/internal/search"
+)
+
+// SearchResult is a snippet matching a search and its relevance. Scores are
+// only comparable between results of the same search on the same backend.
+type SearchResult struct {
+	Snippet
+	Score float64 `json:"score"`
+}
+
+// Search returns up to limit non-expired public snippets, neither protected
+// nor encrypted, whose title or content match any of the words in query, most relevant
+// first. Every word also matches the words it is a prefix of. A non-empty tag
+// restricts the results to the snippets carrying it.
+func (m *SnippetModel) Search(ctx context.Context, query, tag string, limit int) ([]SearchResult, error) {
+	terms := search.Terms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	// The terms are plain words, so they can be put together into each
+	// backend's query syntax without escaping.
+	var stmt, match string
+	switch m.Dialect {
+	case models.SQLite:
+		quoted := make([]string, len(terms))
+		for i, term := range terms {
+			quoted[i] = `"` + term + `"*`
+		}
+		match = strings.Join(quoted, " OR ")
+
+		// bm25 ranks better matches lower, the opposite of the others.
+		stmt = `SELECT ` + snippetColumns + `, -bm25(snippets_fts, 3.0, 1.0) AS score
+		FROM snippets_fts
+		INNER JOIN snippets s ON s.id = snippets_fts.rowid
+		INNER JOIN users u ON u.id = s.user_id
+		WHERE snippets_fts MATCH ? AND s.expires > ? %s
+		ORDER BY score DESC, s.id DESC LIMIT ?`
+
+	case models.Postgres:
+		match = strings.Join(terms, ":* | ") + ":*"
+
+		stmt = `SELECT ` + snippetColumns + `, ts_rank(s.search_vector, q) AS score
+		FROM snippets s
+		INNER JOIN users u ON u.id = s.user_id
+		CROSS JOIN to_tsquery('simple', ?) AS q
+		WHERE s.search_vector @@ q AND s.expires > ? %s
+		ORDER BY score DESC, s.id DESC LIMIT ?`
+
+	default:
+		match = strings.Join(terms, "* ") + "*"
+
+		stmt = `SELECT ` + snippetColumns + `,
+		MATCH (s.title, s.content) AGAINST (? IN BOOLEAN MODE) AS score
+		FROM snippets s
+		INNER JOIN users u ON u.id = s.user_id
+		WHERE MATCH (s.title, s.content) AGAINST (? IN BOOLEAN MODE) AND s.expires > ? %s
+		ORDER BY score DESC, s.id DESC LIMIT ?`
+	}
+
+	args := []any{match, time.Now().UTC()}
+	if m.Dialect == models.MySQL {
+		args = append([]any{match}, args...)
+	}
+
+	filter := "AND " + listedFilter + " AND " + unprotectedFilter + " AND " + plaintextFilter
+	if tag != "" {
+		filter += " AND " + tagFilter
+		args = append(args, tag)
+	}
+
+	stmt = fmt.Sprintf(stmt, filter)
+	args = append(args, limit)
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.Rebind(stmt), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		if err := rows.Scan(append(snippetFields(&r.Snippet), &r.Score)...); err != nil {
+			return nil, err
+		}
+
+		results = append(results, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ptrs := make([]*Snippet, len(results))
+	for i := range results {
+		ptrs[i] = &results[i].Snippet
+	}
+
+	if err := m.loadTags(ctx, m.DB, ptrs...); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// index keeps the SQLite full-text table in step with a snippet, which the
+// other backends do on their own.
+func (m *SnippetModel) index(ctx context.Context, q models.Querier, id int, title, content string) error {
+	if err := m.unindex(ctx, q, id); err != nil || m.Dialect != models.SQLite {
+		return err
+	}
+
+	_, err := q.ExecContext(ctx, `INSERT INTO snippets_fts (rowid, title, content) VALUES (?, ?, ?)`, id, title, content)
+
+	return err
+}
+
+// unindex removes a snippet from the SQLite full-text table.
+func (m *SnippetModel) unindex(ctx context.Context, q models.Querier, id int) error {
+	if m.Dialect != models.SQLite {
+		return nil
+	}
+
+	_, err := q.ExecContext(ctx, `DELETE FROM snippets_fts WHERE rowid = ?`, id)
+
+	return err
+}
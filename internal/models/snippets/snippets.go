@@ -1,6 +1,7 @@
 package snippets
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"time"
@@ -9,42 +10,154 @@ import (
 )
 
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
+	ID      int    `json:"id"`
+	UserID  int    `json:"user_id"`
+	Author  string `json:"author"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	// Language is the name of the language to highlight the content as,
+	// or empty for plain text.
+	Language   string     `json:"language"`
+	Tags       []string   `json:"tags"`
+	Visibility Visibility `json:"visibility"`
+	// Encrypted snippets were encrypted in the browser, and their content
+	// is the ciphertext, as checked by ValidCiphertext.
+	Encrypted bool `json:"encrypted"`
+	// Slug addresses the snippet in links that do not give away its ID.
+	Slug string `json:"slug"`
+	// MaxViews is how many times the snippet can be viewed before it is
+	// deleted, or 0 for no limit. Views counts them.
+	MaxViews int `json:"max_views"`
+	Views    int `json:"views"`
+	// PasswordHash is the bcrypt hash of the password needed to read the
+	// snippet, or empty for a snippet anyone it is visible to can read.
+	PasswordHash string    `json:"-"`
+	Created      time.Time `json:"created"`
+	Expires      time.Time `json:"expires"`
+}
+
+// Draft is the part of a snippet its author writes, as passed to Insert and
+// Update.
+type Draft struct {
+	Title      string
+	Content    string
+	Language   string
+	Tags       []string
+	Visibility Visibility
+	Encrypted  bool
+	MaxViews   int
+	// Password protects the snippet when set. Updates keep the current
+	// password unless given a new one or told to remove it.
+	Password       string
+	RemovePassword bool
+	// Expires is when the snippet expires, or Never. Updates keep the
+	// current expiry when it is zero.
 	Expires time.Time
 }
 
+// SnippetStore is implemented by every storage backend for snippets. Only
+// public snippets without a view limit are listed, searched and counted in
+// Tags; Get, GetBySlug and ByUser return any snippet, leaving it to the
+// caller to check who may read them and to count views with View. Listings
+// leave out the content of protected snippets, and searches leave out both
+// protected and encrypted snippets altogether.
+type SnippetStore interface {
+	Insert(ctx context.Context, userID int, draft Draft) (int, error)
+	Get(ctx context.Context, id int) (Snippet, error)
+	GetBySlug(ctx context.Context, slug string) (Snippet, error)
+	Update(ctx context.Context, id int, draft Draft) error
+	SetExpiry(ctx context.Context, id int, expires time.Time) error
+	Delete(ctx context.Context, id int) error
+	View(ctx context.Context, id int) (Snippet, error)
+	Latest(ctx context.Context) ([]Snippet, error)
+	Recent(ctx context.Context, tag string, limit, offset int) ([]Snippet, error)
+	List(ctx context.Context, opts ListOptions) (Page, error)
+	Search(ctx context.Context, query, tag string, limit int) ([]SearchResult, error)
+	Tags(ctx context.Context) ([]TagCount, error)
+	ByUser(ctx context.Context, userID int) ([]Snippet, error)
+	Revisions(ctx context.Context, id int) ([]Revision, error)
+	Revision(ctx context.Context, id, n int) (Revision, error)
+}
+
 type SnippetModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Dialect models.Dialect
+	// Cost is the bcrypt cost used to hash snippet passwords, DefaultCost
+	// if zero.
+	Cost int
+}
+
+// snippetColumns are the columns of a snippet s joined with its author u,
+// in the order of snippetFields.
+const snippetColumns = `s.id, s.user_id, u.name, s.title, s.content, s.language, s.visibility, s.encrypted, s.slug, s.max_views, s.views, s.password_hash, s.expires, s.created`
+
+// snippetFields returns the destinations to scan snippetColumns into.
+func snippetFields(s *Snippet) []any {
+	return []any{&s.ID, &s.UserID, &s.Author, &s.Title, &s.Content, &s.Language, &s.Visibility, &s.Encrypted, &s.Slug, &s.MaxViews, &s.Views, &s.PasswordHash, &s.Expires, &s.Created}
 }
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	stmt := `INSERT INTO snippets (title, content, expires, created)
+// Insert stores a new snippet together with its first revision.
+func (m *SnippetModel) Insert(ctx context.Context, userID int, draft Draft) (int, error) {
+	// Hash outside the transaction, as bcrypt is slow on purpose.
+	passwordHash, _, err := draft.PasswordHash(m.Cost)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+
+	stmt := `INSERT INTO snippets (user_id, title, content, language, visibility, encrypted, slug, max_views, password_hash, expires, created)
 						 VALUES
-						 (?, ?, DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY), UTC_TIMESTAMP())
+						 (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 			`
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	id, err := m.Dialect.InsertID(ctx, tx, stmt, userID, draft.Title, draft.Content, draft.Language, draft.Visibility, draft.Encrypted, newSlug(), draft.MaxViews, passwordHash, draft.Expires.UTC(), now)
 	if err != nil {
 		return 0, err
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
+	if err := m.insertRevision(ctx, tx, id, draft.Title, draft.Content, now); err != nil {
+		return 0, err
+	}
+
+	if err := m.index(ctx, tx, id, draft.Title, draft.Content); err != nil {
+		return 0, err
+	}
+
+	if err := m.setTags(ctx, tx, id, draft.Tags); err != nil {
 		return 0, err
 	}
 
-	return int(id), nil
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return id, nil
 }
 
 // This will return a specific snippet based on its id.
-func (m *SnippetModel) Get(id int) (Snippet, error) {
+func (m *SnippetModel) Get(ctx context.Context, id int) (Snippet, error) {
+	return m.get(ctx, m.DB, "s.id = ?", id)
+}
+
+// This will return a specific snippet based on its slug.
+func (m *SnippetModel) GetBySlug(ctx context.Context, slug string) (Snippet, error) {
+	return m.get(ctx, m.DB, "s.slug = ?", slug)
+}
+
+func (m *SnippetModel) get(ctx context.Context, q models.Querier, where string, arg any) (Snippet, error) {
 	var snippet Snippet
-	stmt := `SELECT id, title, content, expires, created FROM snippets WHERE id = ? AND expires > UTC_TIMESTAMP()`
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets s
+	INNER JOIN users u ON u.id = s.user_id
+	WHERE ` + where + ` AND s.expires > ?`
 
-	row := m.DB.QueryRow(stmt, id)
-	if err := row.Scan(&snippet.ID, &snippet.Title, &snippet.Content, &snippet.Expires, &snippet.Created); err != nil {
+	row := q.QueryRowContext(ctx, m.Dialect.Rebind(stmt), arg, time.Now().UTC())
+	if err := row.Scan(snippetFields(&snippet)...); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return Snippet{}, models.ErrNoRecord
 		}
@@ -52,15 +165,166 @@ func (m *SnippetModel) Get(id int) (Snippet, error) {
 		return Snippet{}, err
 	}
 
+	if err := m.loadTags(ctx, q, &snippet); err != nil {
+		return Snippet{}, err
+	}
+
 	return snippet, nil
 }
 
+// This will update a specific snippet, keeping the new title and content as
+// its next revision. The other fields are not part of the revision history.
+// Making a snippet unlisted gives it a new slug, so that its old links stop
+// working if it had been shared before. Changing the view limit starts the
+// view count again, as a limit at or below the views a snippet has had would
+// leave it neither readable nor burned. Encrypted snippets only keep their
+// latest revision.
+func (m *SnippetModel) Update(ctx context.Context, id int, draft Draft) error {
+	// Hash outside the transaction, as bcrypt is slow on purpose.
+	passwordHash, changed, err := draft.PasswordHash(m.Cost)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+
+	// MySQL assigns from left to right, so the slug and the view count must
+	// be decided before the visibility and the view limit change.
+	stmt := `UPDATE snippets SET slug = CASE WHEN visibility = ? THEN slug ELSE ? END,
+	views = CASE WHEN max_views = ? THEN views ELSE 0 END,
+	title = ?, content = ?, language = ?, visibility = ?, encrypted = ?, max_views = ?`
+
+	args := []any{Unlisted, newSlug(), draft.MaxViews, draft.Title, draft.Content, draft.Language, draft.Visibility, draft.Encrypted, draft.MaxViews}
+	if !draft.Expires.IsZero() {
+		stmt += `, expires = ?`
+		args = append(args, draft.Expires.UTC())
+	}
+
+	if changed {
+		stmt += `, password_hash = ?`
+		args = append(args, passwordHash)
+	}
+
+	stmt += ` WHERE id = ?`
+	args = append(args, id)
+
+	if _, err := tx.ExecContext(ctx, m.Dialect.Rebind(stmt), args...); err != nil {
+		return err
+	}
+
+	// Encrypted snippets keep no history: ciphertext cannot be diffed, and
+	// earlier plaintext revisions would give away what the encryption hides.
+	if draft.Encrypted {
+		if _, err := tx.ExecContext(ctx, m.Dialect.Rebind(`DELETE FROM snippet_revisions WHERE snippet_id = ?`), id); err != nil {
+			return err
+		}
+	}
+
+	if err := m.insertRevision(ctx, tx, id, draft.Title, draft.Content, now); err != nil {
+		return err
+	}
+
+	if err := m.index(ctx, tx, id, draft.Title, draft.Content); err != nil {
+		return err
+	}
+
+	if err := m.setTags(ctx, tx, id, draft.Tags); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// This will remove a specific snippet based on its id.
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.delete(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// delete removes a snippet with everything that refers to it.
+func (m *SnippetModel) delete(ctx context.Context, tx *sql.Tx, id int) error {
+	if _, err := tx.ExecContext(ctx, m.Dialect.Rebind(`DELETE FROM snippet_revisions WHERE snippet_id = ?`), id); err != nil {
+		return err
+	}
+
+	if err := m.unindex(ctx, tx, id); err != nil {
+		return err
+	}
+
+	if err := m.setTags(ctx, tx, id, nil); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, m.Dialect.Rebind(`DELETE FROM snippets WHERE id = ?`), id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
 // This will return the 10 most recently created snippets.
-func (m *SnippetModel) Latest() ([]Snippet, error) {
+func (m *SnippetModel) Latest(ctx context.Context) ([]Snippet, error) {
+	return m.Recent(ctx, "", 10, 0)
+}
+
+// This will return up to limit non-expired public snippets, newest first,
+// after skipping the first offset of them. A non-empty tag restricts them to
+// the snippets carrying it.
+func (m *SnippetModel) Recent(ctx context.Context, tag string, limit, offset int) ([]Snippet, error) {
+	where := "s.expires > ? AND " + listedFilter
+	args := []any{time.Now().UTC()}
+
+	if tag != "" {
+		where += " AND " + tagFilter
+		args = append(args, tag)
+	}
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets s
+	INNER JOIN users u ON u.id = s.user_id
+	WHERE ` + where + ` ORDER BY s.id DESC LIMIT ? OFFSET ?`
+
+	return m.query(ctx, stmt, append(args, limit, offset)...)
+}
+
+// This will return every non-expired snippet created by a specific user,
+// newest first.
+func (m *SnippetModel) ByUser(ctx context.Context, userID int) ([]Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets s
+	INNER JOIN users u ON u.id = s.user_id
+	WHERE s.user_id = ? AND s.expires > ? ORDER BY s.id DESC`
+
+	return m.query(ctx, stmt, userID, time.Now().UTC())
+}
+
+func (m *SnippetModel) query(ctx context.Context, stmt string, args ...any) ([]Snippet, error) {
 	var snippets []Snippet
 
-	stmt := `SELECT id, title, content, expires, created FROM snippets WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
-	rows, err := m.DB.Query(stmt)
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.Rebind(stmt), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -68,10 +332,11 @@ func (m *SnippetModel) Latest() ([]Snippet, error) {
 
 	for rows.Next() {
 		var snippet Snippet
-		if err := rows.Scan(&snippet.ID, &snippet.Title, &snippet.Content, &snippet.Expires, &snippet.Created); err != nil {
-			return nil, nil
+		if err := rows.Scan(snippetFields(&snippet)...); err != nil {
+			return nil, err
 		}
 
+		Redact(&snippet)
 		snippets = append(snippets, snippet)
 	}
 
@@ -79,5 +344,14 @@ func (m *SnippetModel) Latest() ([]Snippet, error) {
 		return nil, err
 	}
 
+	ptrs := make([]*Snippet, len(snippets))
+	for i := range snippets {
+		ptrs[i] = &snippets[i]
+	}
+
+	if err := m.loadTags(ctx, m.DB, ptrs...); err != nil {
+		return nil, err
+	}
+
 	return snippets, nil
 }
@@ -0,0 +1,110 @@
+package snippets
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/models"
+)
+
+// TagCount is a tag and how many non-expired public snippets carry it.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// SortTags returns a sorted copy of tags without duplicates, which is how
+// the stores hand tags back. It never returns nil, so that a snippet without
+// tags has an empty list in JSON.
+func SortTags(tags []string) []string {
+	sorted := slices.Clone(tags)
+	slices.Sort(sorted)
+
+	return append([]string{}, slices.Compact(sorted)...)
+}
+
+// Tags returns every tag on a non-expired public snippet with its number of
+// snippets, in alphabetical order.
+func (m *SnippetModel) Tags(ctx context.Context) ([]TagCount, error) {
+	stmt := `SELECT t.tag, COUNT(*) FROM snippet_tags t
+	INNER JOIN snippets s ON s.id = t.snippet_id
+	WHERE s.expires > ? AND ` + listedFilter + ` GROUP BY t.tag ORDER BY t.tag`
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.Rebind(stmt), time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []TagCount
+	for rows.Next() {
+		var c TagCount
+		if err := rows.Scan(&c.Tag, &c.Count); err != nil {
+			return nil, err
+		}
+
+		counts = append(counts, c)
+	}
+
+	return counts, rows.Err()
+}
+
+// setTags replaces the tags of a snippet.
+func (m *SnippetModel) setTags(ctx context.Context, tx *sql.Tx, id int, tags []string) error {
+	if _, err := tx.ExecContext(ctx, m.Dialect.Rebind(`DELETE FROM snippet_tags WHERE snippet_id = ?`), id); err != nil {
+		return err
+	}
+
+	for _, tag := range SortTags(tags) {
+		stmt := `INSERT INTO snippet_tags (snippet_id, tag) VALUES (?, ?)`
+		if _, err := tx.ExecContext(ctx, m.Dialect.Rebind(stmt), id, tag); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadTags fills in the tags of the snippets with a single query.
+func (m *SnippetModel) loadTags(ctx context.Context, q models.Querier, snippets ...*Snippet) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*Snippet, len(snippets))
+	args := make([]any, 0, len(snippets))
+	for _, s := range snippets {
+		s.Tags = []string{}
+		byID[s.ID] = s
+		args = append(args, s.ID)
+	}
+
+	stmt := `SELECT snippet_id, tag FROM snippet_tags WHERE snippet_id IN (?` + strings.Repeat(", ?", len(args)-1) + `) ORDER BY tag`
+
+	rows, err := q.QueryContext(ctx, m.Dialect.Rebind(stmt), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id  int
+			tag string
+		)
+		if err := rows.Scan(&id, &tag); err != nil {
+			return err
+		}
+
+		byID[id].Tags = append(byID[id].Tags, tag)
+	}
+
+	return rows.Err()
+}
+
+// tagFilter is the condition restricting a query on snippets s to those
+// carrying a tag.
+const tagFilter = "s.id IN (SELECT snippet_id FROM snippet_tags WHERE tag = ?)"
@@ -0,0 +1,65 @@
+package snippets
+
+import (
+	"context"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/models"
+)
+
+// ViewsLeft returns how many more times a snippet with a view limit can be
+// viewed, or -1 for a snippet without one.
+func (s Snippet) ViewsLeft() int {
+	if s.MaxViews == 0 {
+		return -1
+	}
+
+	return max(s.MaxViews-s.Views, 0)
+}
+
+// View counts a view of a specific snippet and returns it. A snippet limited
+// to a number of views is deleted by the view that uses up the last of them.
+// The views are counted by a conditional update, so that of two concurrent
+// views of a snippet with one view left only one succeeds. It returns
+// models.ErrNoRecord for snippets that have no views left.
+func (m *SnippetModel) View(ctx context.Context, id int) (Snippet, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Snippet{}, err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE snippets SET views = views + 1
+	WHERE id = ? AND expires > ? AND (max_views = 0 OR views < max_views)`
+
+	result, err := tx.ExecContext(ctx, m.Dialect.Rebind(stmt), id, time.Now().UTC())
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	if rows == 0 {
+		return Snippet{}, models.ErrNoRecord
+	}
+
+	snippet, err := m.get(ctx, tx, "s.id = ?", id)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	if snippet.ViewsLeft() == 0 {
+		if err := m.delete(ctx, tx, id); err != nil {
+			return Snippet{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Snippet{}, err
+	}
+
+	return snippet, nil
+}
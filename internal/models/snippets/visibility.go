@@ -0,0 +1,50 @@
+package snippets
+
+import "crypto/rand"
+
+// Visibility is who can find and read a snippet.
+type Visibility string
+
+const (
+	// Public snippets are listed and searchable, and anyone can read them.
+	Public Visibility = "public"
+	// Unlisted snippets can be read by anyone with their link, which has
+	// their slug rather than their guessable ID.
+	Unlisted Visibility = "unlisted"
+	// Private snippets can only be read by their author.
+	Private Visibility = "private"
+)
+
+// Visibilities lists every visibility, the default first.
+var Visibilities = []Visibility{Public, Unlisted, Private}
+
+// VisibleTo reports whether a user, or a visitor for a userID of 0, may read
+// the snippet when they ask for it by ID.
+func (s Snippet) VisibleTo(userID int) bool {
+	return s.Visibility == Public || (userID != 0 && s.UserID == userID)
+}
+
+// VisibleBySlugTo reports whether a user, or a visitor for a userID of 0,
+// may read the snippet when they ask for it by slug.
+func (s Snippet) VisibleBySlugTo(userID int) bool {
+	return s.Visibility != Private || (userID != 0 && s.UserID == userID)
+}
+
+// HistoryVisibleTo reports whether a user, or a visitor for a userID of 0,
+// may browse the revisions of the snippet. Only the author can for snippets
+// with a view limit, as revisions are shown without counting a view, and
+// nobody can for encrypted snippets, whose revisions cannot be compared.
+func (s Snippet) HistoryVisibleTo(userID int) bool {
+	return s.VisibleTo(userID) && (s.MaxViews == 0 || s.UserID == userID) && !s.Encrypted
+}
+
+// newSlug returns a random slug for a snippet, long enough not to be
+// guessed.
+func newSlug() string {
+	return rand.Text()
+}
+
+// listedFilter is the condition restricting a query on snippets s to those
+// that are listed and searchable. Snippets with a view limit never are, as
+// listings show their content without counting a view.
+const listedFilter = "s.visibility = '" + string(Public) + "' AND s.max_views = 0"
@@ -0,0 +1,958 @@
+// Package storetest holds the behavioural test suite that every storage
+// backend has to pass, so that the handlers can rely on identical semantics
+// whichever one they are given.
+package storetest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/assert"
+	"github.com/yousifsabah0/snippets/internal/models"
+	"github.com/yousifsabah0/snippets/internal/models/snippets"
+	"github.com/yousifsabah0/snippets/internal/models/tokens"
+	"github.com/yousifsabah0/snippets/internal/models/users"
+)
+
+// Stores is one backend's set of stores, sharing the same empty database.
+type Stores struct {
+	Snippets snippets.SnippetStore
+	Users    users.UserStore
+	Tokens   tokens.TokenStore
+}
+
+// Run runs the suite, calling newStores to get empty stores for every test.
+func Run(t *testing.T, newStores func(t *testing.T) Stores) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s Stores)
+	}{
+		{"Users", testUsers},
+		{"SnippetInsertGet", testSnippetInsertGet},
+		{"SnippetExpiry", testSnippetExpiry},
+		{"SnippetUpdateRevisions", testSnippetUpdateRevisions},
+		{"SnippetDelete", testSnippetDelete},
+		{"SnippetListing", testSnippetListing},
+		{"SnippetList", testSnippetList},
+		{"SnippetSearch", testSnippetSearch},
+		{"SnippetTags", testSnippetTags},
+		{"SnippetVisibility", testSnippetVisibility},
+		{"SnippetViewLimit", testSnippetViewLimit},
+		{"SnippetPassword", testSnippetPassword},
+		{"SnippetEncrypted", testSnippetEncrypted},
+		{"Tokens", testTokens},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.fn(t, newStores(t))
+		})
+	}
+}
+
+// inDays returns the time n days from now, for snippets to expire at.
+func inDays(n int) time.Time {
+	return time.Now().AddDate(0, 0, n)
+}
+
+// newUser inserts a user and returns its ID, which the suite assumes are
+// handed out from 1 upwards on an empty database.
+func newUser(t *testing.T, s Stores, name, email string) int {
+	t.Helper()
+
+	if err := s.Users.Insert(t.Context(), name, email, "pa55word"); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := s.Users.Authenticate(t.Context(), email, "pa55word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
+
+func newSnippet(t *testing.T, s Stores, userID int, title string) int {
+	t.Helper()
+
+	id, err := s.Snippets.Insert(t.Context(), userID, snippets.Draft{Title: title, Content: title + " content", Visibility: snippets.Public, Expires: inDays(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
+
+func testUsers(t *testing.T, s Stores) {
+	id := newUser(t, s, "Alice", "alice@example.com")
+
+	err := s.Users.Insert(t.Context(), "Alice Again", "alice@example.com", "pa55word")
+	assert.Equal(t, errors.Is(err, models.ErrDuplicateEmail), true)
+
+	_, err = s.Users.Authenticate(t.Context(), "alice@example.com", "wrong password")
+	assert.Equal(t, errors.Is(err, models.ErrInvalidCredentials), true)
+
+	_, err = s.Users.Authenticate(t.Context(), "nobody@example.com", "pa55word")
+	assert.Equal(t, errors.Is(err, models.ErrInvalidCredentials), true)
+
+	exists, err := s.Users.Exists(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, exists, true)
+
+	exists, err = s.Users.Exists(t.Context(), id+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, exists, false)
+}
+
+func testSnippetInsertGet(t *testing.T, s Stores) {
+	userID := newUser(t, s, "Alice", "alice@example.com")
+
+	before := time.Now().UTC().Add(-time.Second)
+	id := newSnippet(t, s, userID, "First")
+
+	snippet, err := s.Snippets.Get(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, snippet.ID, id)
+	assert.Equal(t, snippet.UserID, userID)
+	assert.Equal(t, snippet.Author, "Alice")
+	assert.Equal(t, snippet.Title, "First")
+	assert.Equal(t, snippet.Content, "First content")
+	assert.Equal(t, snippet.Language, "")
+	assert.Equal(t, snippet.Created.After(before), true)
+	assert.Equal(t, snippet.Expires.Sub(snippet.Created).Round(time.Hour), 7*24*time.Hour)
+
+	_, err = s.Snippets.Get(t.Context(), id+1)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+}
+
+func testSnippetExpiry(t *testing.T, s Stores) {
+	userID := newUser(t, s, "Alice", "alice@example.com")
+
+	// A snippet that expires now is already expired by the time it is read
+	// back.
+	id, err := s.Snippets.Insert(t.Context(), userID, snippets.Draft{Title: "Expired", Content: "content", Visibility: snippets.Public, Expires: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Snippets.Get(t.Context(), id)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+	latest, err := s.Snippets.Latest(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(latest), 0)
+
+	mine, err := s.Snippets.ByUser(t.Context(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(mine), 0)
+
+	// Expired snippets cannot be brought back.
+	err = s.Snippets.SetExpiry(t.Context(), id, inDays(1))
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+	draft := snippets.Draft{Title: "Kept", Content: "content", Visibility: snippets.Public, Expires: time.Now().Add(10 * time.Minute)}
+	id, err = s.Snippets.Insert(t.Context(), userID, draft)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snippet, err := s.Snippets.Get(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, snippet.TimeLeft(time.Now()).Round(time.Minute), 10*time.Minute)
+
+	// Updates without an expiry keep the current one.
+	draft.Expires = time.Time{}
+	if err := s.Snippets.Update(t.Context(), id, draft); err != nil {
+		t.Fatal(err)
+	}
+
+	snippet, err = s.Snippets.Get(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, snippet.TimeLeft(time.Now()).Round(time.Minute), 10*time.Minute)
+
+	// Setting the same expiry again still finds the snippet.
+	for range 2 {
+		if err := s.Snippets.SetExpiry(t.Context(), id, snippets.Never); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snippet, err = s.Snippets.Get(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, snippet.NeverExpires(), true)
+	assert.Equal(t, snippet.Expires.Equal(snippets.Never), true)
+
+	// Shortening the expiry into the past expires the snippet.
+	if err := s.Snippets.SetExpiry(t.Context(), id, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Snippets.Get(t.Context(), id)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+}
+
+func testSnippetUpdateRevisions(t *testing.T, s Stores) {
+	userID := newUser(t, s, "Alice", "alice@example.com")
+	id := newSnippet(t, s, userID, "First")
+
+	if err := s.Snippets.Update(t.Context(), id, snippets.Draft{Title: "Second", Content: "second content", Language: "Go", Visibility: snippets.Public, Expires: inDays(1)}); err != nil {
+		t.Fatal(err)
+	}
+
+	snippet, err := s.Snippets.Get(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, snippet.Title, "Second")
+	assert.Equal(t, snippet.Content, "second content")
+	assert.Equal(t, snippet.Language, "Go")
+	assert.Equal(t, snippet.Expires.Sub(time.Now()) < 25*time.Hour, true)
+
+	revisions, err := s.Snippets.Revisions(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(revisions), 2)
+	assert.Equal(t, revisions[0].Number, 2)
+	assert.Equal(t, revisions[0].Title, "Second")
+	assert.Equal(t, revisions[1].Number, 1)
+	assert.Equal(t, revisions[1].Content, "First content")
+
+	revision, err := s.Snippets.Revision(t.Context(), id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, revision.SnippetID, id)
+	assert.Equal(t, revision.Title, "First")
+
+	_, err = s.Snippets.Revision(t.Context(), id, 3)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+}
+
+func testSnippetDelete(t *testing.T, s Stores) {
+	userID := newUser(t, s, "Alice", "alice@example.com")
+	id := newSnippet(t, s, userID, "First")
+
+	if err := s.Snippets.Delete(t.Context(), id); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := s.Snippets.Get(t.Context(), id)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+	revisions, err := s.Snippets.Revisions(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(revisions), 0)
+
+	err = s.Snippets.Delete(t.Context(), id)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+}
+
+func testSnippetListing(t *testing.T, s Stores) {
+	alice := newUser(t, s, "Alice", "alice@example.com")
+	bob := newUser(t, s, "Bob", "bob@example.com")
+
+	var ids []int
+	for i := range 12 {
+		userID := alice
+		if i%3 == 0 {
+			userID = bob
+		}
+
+		ids = append(ids, newSnippet(t, s, userID, "Snippet"))
+	}
+
+	latest, err := s.Snippets.Latest(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(latest), 10)
+	assert.Equal(t, latest[0].ID, ids[11])
+	assert.Equal(t, latest[9].ID, ids[2])
+
+	page, err := s.Snippets.Recent(t.Context(), "", 5, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(page), 2)
+	assert.Equal(t, page[1].ID, ids[0])
+
+	mine, err := s.Snippets.ByUser(t.Context(), bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(mine), 4)
+	for _, snippet := range mine {
+		assert.Equal(t, snippet.Author, "Bob")
+	}
+}
+
+func testSnippetList(t *testing.T, s Stores) {
+	alice := newUser(t, s, "Alice", "alice@example.com")
+	bob := newUser(t, s, "Bob", "bob@example.com")
+
+	// Expiry in days, chosen so the expiring order differs from the ID order
+	// and two of them expire on the same day.
+	var ids []int
+	for i, days := range []int{7, 1, 365, 7, 30} {
+		userID := alice
+		if i%2 == 1 {
+			userID = bob
+		}
+
+		id, err := s.Snippets.Insert(t.Context(), userID, snippets.Draft{Title: "Snippet", Content: "content", Visibility: snippets.Public, Expires: inDays(days)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	list := func(opts snippets.ListOptions) snippets.Page {
+		t.Helper()
+
+		page, err := s.Snippets.List(t.Context(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return page
+	}
+
+	pageIDs := func(page snippets.Page) []int {
+		var got []int
+		for _, snippet := range page.Snippets {
+			got = append(got, snippet.ID)
+		}
+		return got
+	}
+
+	// Walk forwards through the newest first listing, then back again.
+	first := list(snippets.ListOptions{Limit: 2})
+	assert.Equal(t, fmt.Sprint(pageIDs(first)), fmt.Sprint([]int{ids[4], ids[3]}))
+	assert.Equal(t, first.Prev, "")
+
+	second := list(snippets.ListOptions{Limit: 2, After: first.Next})
+	assert.Equal(t, fmt.Sprint(pageIDs(second)), fmt.Sprint([]int{ids[2], ids[1]}))
+
+	third := list(snippets.ListOptions{Limit: 2, After: second.Next})
+	assert.Equal(t, fmt.Sprint(pageIDs(third)), fmt.Sprint([]int{ids[0]}))
+	assert.Equal(t, third.Next, "")
+
+	back := list(snippets.ListOptions{Limit: 2, Before: third.Prev})
+	assert.Equal(t, fmt.Sprint(pageIDs(back)), fmt.Sprint(pageIDs(second)))
+
+	back = list(snippets.ListOptions{Limit: 2, Before: back.Prev})
+	assert.Equal(t, fmt.Sprint(pageIDs(back)), fmt.Sprint(pageIDs(first)))
+	assert.Equal(t, back.Prev, "")
+
+	oldest := list(snippets.ListOptions{Sort: snippets.SortOldest, Limit: 3})
+	assert.Equal(t, fmt.Sprint(pageIDs(oldest)), fmt.Sprint(ids[:3]))
+
+	expiring := list(snippets.ListOptions{Sort: snippets.SortExpiring, Limit: 2})
+	assert.Equal(t, fmt.Sprint(pageIDs(expiring)), fmt.Sprint([]int{ids[1], ids[0]}))
+
+	expiring = list(snippets.ListOptions{Sort: snippets.SortExpiring, Limit: 2, After: expiring.Next})
+	assert.Equal(t, fmt.Sprint(pageIDs(expiring)), fmt.Sprint([]int{ids[3], ids[4]}))
+
+	byBob := list(snippets.ListOptions{Author: "Bob"})
+	assert.Equal(t, fmt.Sprint(pageIDs(byBob)), fmt.Sprint([]int{ids[3], ids[1]}))
+
+	now := time.Now().UTC()
+	assert.Equal(t, len(list(snippets.ListOptions{From: now.Add(-time.Hour), To: now.Add(time.Hour)}).Snippets), 5)
+	assert.Equal(t, len(list(snippets.ListOptions{From: now.Add(time.Hour)}).Snippets), 0)
+	assert.Equal(t, len(list(snippets.ListOptions{To: now.Add(-time.Hour)}).Snippets), 0)
+
+	_, err := s.Snippets.List(t.Context(), snippets.ListOptions{After: "not a cursor"})
+	assert.Equal(t, errors.Is(err, snippets.ErrInvalidCursor), true)
+}
+
+func testSnippetSearch(t *testing.T, s Stores) {
+	userID := newUser(t, s, "Alice", "alice@example.com")
+
+	insert := func(title, content string, days int) int {
+		t.Helper()
+
+		id, err := s.Snippets.Insert(t.Context(), userID, snippets.Draft{Title: title, Content: content, Visibility: snippets.Public, Expires: inDays(days)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return id
+	}
+
+	pond := insert("An old silent pond", "A frog jumps into the pond, splash! Silence again.", 7)
+	forest := insert("Over the wintry forest", "Winds howl in rage with no leaves to blow, not even near the pond.", 7)
+	insert("First autumn morning", "The mirror I stare into shows my father's face.", 7)
+	// Expired snippets are left out, as they are by Get.
+	insert("Expired pond", "pond pond pond", 0)
+
+	searchIDs := func(query string, limit int) []int {
+		t.Helper()
+
+		results, err := s.Snippets.Search(t.Context(), query, "", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ids []int
+		for _, r := range results {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+
+	// The title match ranks first.
+	assert.Equal(t, fmt.Sprint(searchIDs("pond", 10)), fmt.Sprint([]int{pond, forest}))
+	assert.Equal(t, fmt.Sprint(searchIDs("pond", 1)), fmt.Sprint([]int{pond}))
+	assert.Equal(t, fmt.Sprint(searchIDs("WINTRY", 10)), fmt.Sprint([]int{forest}))
+	// Words match as prefixes, and punctuation is not query syntax.
+	assert.Equal(t, fmt.Sprint(searchIDs(`"wint" -*`, 10)), fmt.Sprint([]int{forest}))
+	assert.Equal(t, len(searchIDs("tadpole", 10)), 0)
+	assert.Equal(t, len(searchIDs("  ", 10)), 0)
+
+	results, err := s.Snippets.Search(t.Context(), "frog", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].Author, "Alice")
+	assert.Equal(t, results[0].Content, "A frog jumps into the pond, splash! Silence again.")
+	assert.Equal(t, results[0].Score > 0, true)
+
+	if err := s.Snippets.Update(t.Context(), forest, snippets.Draft{Title: "Over the wintry forest", Content: "Winds howl in rage.", Visibility: snippets.Public, Expires: inDays(7)}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, fmt.Sprint(searchIDs("pond", 10)), fmt.Sprint([]int{pond}))
+
+	if err := s.Snippets.Delete(t.Context(), pond); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(searchIDs("pond", 10)), 0)
+}
+
+func testSnippetTags(t *testing.T, s Stores) {
+	userID := newUser(t, s, "Alice", "alice@example.com")
+
+	insert := func(title string, tags []string, days int) int {
+		t.Helper()
+
+		id, err := s.Snippets.Insert(t.Context(), userID, snippets.Draft{Title: title, Content: title + " content", Tags: tags, Visibility: snippets.Public, Expires: inDays(days)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return id
+	}
+
+	deploy := insert("Deploy", []string{"k8s", "bash", "k8s"}, 7)
+	rollback := insert("Rollback", []string{"k8s", "oncall"}, 7)
+	untagged := insert("Untagged", nil, 7)
+	insert("Expired", []string{"bash"}, 0)
+
+	snippet, err := s.Snippets.Get(t.Context(), deploy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, fmt.Sprint(snippet.Tags), "[bash k8s]")
+
+	snippet, err = s.Snippets.Get(t.Context(), untagged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, snippet.Tags != nil && len(snippet.Tags) == 0, true)
+
+	tags, err := s.Snippets.Tags(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, fmt.Sprint(tags), "[{bash 1} {k8s 2} {oncall 1}]")
+
+	recent, err := s.Snippets.Recent(t.Context(), "k8s", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(recent), 2)
+	assert.Equal(t, recent[0].ID, rollback)
+	assert.Equal(t, fmt.Sprint(recent[0].Tags), "[k8s oncall]")
+
+	page, err := s.Snippets.List(t.Context(), snippets.ListOptions{Tag: "oncall"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(page.Snippets), 1)
+	assert.Equal(t, page.Snippets[0].ID, rollback)
+
+	results, err := s.Snippets.Search(t.Context(), "content", "bash", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].ID, deploy)
+	assert.Equal(t, fmt.Sprint(results[0].Tags), "[bash k8s]")
+
+	if err := s.Snippets.Update(t.Context(), deploy, snippets.Draft{Title: "Deploy", Content: "content", Tags: []string{"helm"}, Visibility: snippets.Public, Expires: inDays(7)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Snippets.Delete(t.Context(), rollback); err != nil {
+		t.Fatal(err)
+	}
+
+	tags, err = s.Snippets.Tags(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, fmt.Sprint(tags), "[{helm 1}]")
+}
+
+func testSnippetVisibility(t *testing.T, s Stores) {
+	alice := newUser(t, s, "Alice", "alice@example.com")
+	bob := newUser(t, s, "Bob", "bob@example.com")
+
+	ids := map[snippets.Visibility]int{}
+	for _, visibility := range snippets.Visibilities {
+		draft := snippets.Draft{Title: "Visible " + string(visibility), Content: "content", Tags: []string{"vis"}, Visibility: visibility, Expires: inDays(7)}
+
+		id, err := s.Snippets.Insert(t.Context(), alice, draft)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[visibility] = id
+	}
+
+	// Only public snippets are listed, searched and counted.
+	latest, err := s.Snippets.Latest(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(latest), 1)
+	assert.Equal(t, latest[0].ID, ids[snippets.Public])
+
+	page, err := s.Snippets.List(t.Context(), snippets.ListOptions{Tag: "vis"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(page.Snippets), 1)
+	assert.Equal(t, page.Snippets[0].ID, ids[snippets.Public])
+
+	results, err := s.Snippets.Search(t.Context(), "visible", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].ID, ids[snippets.Public])
+
+	tags, err := s.Snippets.Tags(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, fmt.Sprint(tags), "[{vis 1}]")
+
+	mine, err := s.Snippets.ByUser(t.Context(), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(mine), 3)
+
+	// Every snippet can be got by ID or slug; who may read it is up to the
+	// caller.
+	unlisted, err := s.Snippets.Get(t.Context(), ids[snippets.Unlisted])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, unlisted.Visibility, snippets.Unlisted)
+	assert.Equal(t, len(unlisted.Slug) >= 20, true)
+
+	bySlug, err := s.Snippets.GetBySlug(t.Context(), unlisted.Slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, bySlug.ID, unlisted.ID)
+
+	_, err = s.Snippets.GetBySlug(t.Context(), "not-a-slug")
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+	assert.Equal(t, unlisted.VisibleTo(0), false)
+	assert.Equal(t, unlisted.VisibleTo(alice), true)
+	assert.Equal(t, unlisted.VisibleBySlugTo(0), true)
+
+	private, err := s.Snippets.Get(t.Context(), ids[snippets.Private])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, private.VisibleBySlugTo(bob), false)
+	assert.Equal(t, private.VisibleBySlugTo(alice), true)
+
+	// Staying unlisted keeps the slug, and becoming unlisted changes it.
+	draft := snippets.Draft{Title: "Still unlisted", Content: "content", Visibility: snippets.Unlisted, Expires: inDays(7)}
+	if err := s.Snippets.Update(t.Context(), unlisted.ID, draft); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := s.Snippets.Get(t.Context(), unlisted.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, updated.Slug, unlisted.Slug)
+
+	if err := s.Snippets.Update(t.Context(), private.ID, draft); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err = s.Snippets.Get(t.Context(), private.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, updated.Visibility, snippets.Unlisted)
+	assert.Equal(t, updated.Slug != private.Slug, true)
+}
+
+func testSnippetViewLimit(t *testing.T, s Stores) {
+	userID := newUser(t, s, "Alice", "alice@example.com")
+	unlimited := newSnippet(t, s, userID, "Unlimited")
+
+	insert := func(title string, maxViews int) int {
+		id, err := s.Snippets.Insert(t.Context(), userID, snippets.Draft{Title: title, Content: "secret", Visibility: snippets.Public, MaxViews: maxViews, Expires: inDays(7)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	once := insert("Once", 1)
+	thrice := insert("Thrice", 3)
+
+	// Snippets with a view limit are never listed.
+	latest, err := s.Snippets.Latest(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(latest), 1)
+	assert.Equal(t, latest[0].ID, unlimited)
+
+	// Get does not count as a view.
+	snippet, err := s.Snippets.Get(t.Context(), thrice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, snippet.ViewsLeft(), 3)
+
+	for _, left := range []int{2, 1} {
+		snippet, err = s.Snippets.View(t.Context(), thrice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, snippet.Content, "secret")
+		assert.Equal(t, snippet.ViewsLeft(), left)
+	}
+
+	snippet, err = s.Snippets.View(t.Context(), unlimited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, snippet.Views, 1)
+	assert.Equal(t, snippet.ViewsLeft(), -1)
+
+	// Of many concurrent views of a snippet with one view left, exactly one
+	// gets to read it.
+	var (
+		wg   sync.WaitGroup
+		read atomic.Int32
+	)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := s.Snippets.View(t.Context(), once)
+			if err == nil {
+				read.Add(1)
+			} else if !errors.Is(err, models.ErrNoRecord) {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, read.Load(), int32(1))
+
+	// The last view deletes the snippet.
+	_, err = s.Snippets.Get(t.Context(), once)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+	revisions, err := s.Snippets.Revisions(t.Context(), once)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(revisions), 0)
+
+	_, err = s.Snippets.View(t.Context(), thrice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Snippets.View(t.Context(), thrice)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+	// Editing a snippet keeps its view count, unless the view limit changes:
+	// a limit at or below the views so far would lock it otherwise.
+	edited := insert("Edited", 3)
+	for range 2 {
+		if _, err := s.Snippets.View(t.Context(), edited); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	draft := snippets.Draft{Title: "Edited", Content: "secret", Visibility: snippets.Public, MaxViews: 3}
+	if err := s.Snippets.Update(t.Context(), edited, draft); err != nil {
+		t.Fatal(err)
+	}
+
+	snippet, err = s.Snippets.Get(t.Context(), edited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, snippet.Views, 2)
+
+	draft.MaxViews = 1
+	if err := s.Snippets.Update(t.Context(), edited, draft); err != nil {
+		t.Fatal(err)
+	}
+
+	snippet, err = s.Snippets.View(t.Context(), edited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, snippet.ViewsLeft(), 0)
+
+	_, err = s.Snippets.Get(t.Context(), edited)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+}
+
+func testSnippetPassword(t *testing.T, s Stores) {
+	userID := newUser(t, s, "Alice", "alice@example.com")
+
+	draft := snippets.Draft{Title: "Locked", Content: "hunter2 lives here", Visibility: snippets.Public, Password: "correct horse", Expires: inDays(7)}
+	id, err := s.Snippets.Insert(t.Context(), userID, draft)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Get returns the content, leaving it to the caller to ask for the
+	// password.
+	snippet, err := s.Snippets.Get(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, snippet.Protected(), true)
+	assert.Equal(t, snippet.Content, "hunter2 lives here")
+	assert.Equal(t, snippet.CheckPassword("correct horse"), true)
+	assert.Equal(t, snippet.CheckPassword("wrong horse"), false)
+
+	// Listings show protected snippets without their content.
+	latest, err := s.Snippets.Latest(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(latest), 1)
+	assert.Equal(t, latest[0].Content, "")
+
+	page, err := s.Snippets.List(t.Context(), snippets.ListOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(page.Snippets), 1)
+	assert.Equal(t, page.Snippets[0].Content, "")
+
+	mine, err := s.Snippets.ByUser(t.Context(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(mine), 1)
+	assert.Equal(t, mine[0].Content, "")
+
+	// Searches leave them out, as matches would give their content away.
+	results, err := s.Snippets.Search(t.Context(), "hunter2", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(results), 0)
+
+	// Updates keep the password unless given a new one.
+	draft.Password = ""
+	if err := s.Snippets.Update(t.Context(), id, draft); err != nil {
+		t.Fatal(err)
+	}
+
+	snippet, err = s.Snippets.Get(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, snippet.CheckPassword("correct horse"), true)
+
+	draft.Password = "battery staple"
+	if err := s.Snippets.Update(t.Context(), id, draft); err != nil {
+		t.Fatal(err)
+	}
+
+	snippet, err = s.Snippets.Get(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, snippet.CheckPassword("correct horse"), false)
+	assert.Equal(t, snippet.CheckPassword("battery staple"), true)
+
+	draft.Password = ""
+	draft.RemovePassword = true
+	if err := s.Snippets.Update(t.Context(), id, draft); err != nil {
+		t.Fatal(err)
+	}
+
+	snippet, err = s.Snippets.Get(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, snippet.Protected(), false)
+	assert.Equal(t, snippet.CheckPassword(""), false)
+
+	results, err = s.Snippets.Search(t.Context(), "hunter2", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(results), 1)
+}
+
+func testSnippetEncrypted(t *testing.T, s Stores) {
+	userID := newUser(t, s, "Alice", "alice@example.com")
+
+	ciphertext := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x5a}, 40))
+
+	draft := snippets.Draft{Title: "Launch codes", Content: ciphertext, Visibility: snippets.Public, Encrypted: true, Expires: inDays(7)}
+	id, err := s.Snippets.Insert(t.Context(), userID, draft)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snippet, err := s.Snippets.Get(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, snippet.Encrypted, true)
+	assert.Equal(t, snippet.Content, ciphertext)
+	assert.Equal(t, snippet.HistoryVisibleTo(userID), false)
+
+	// Encrypted snippets are listed, but not searched even by their title.
+	latest, err := s.Snippets.Latest(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(latest), 1)
+	assert.Equal(t, latest[0].Encrypted, true)
+
+	results, err := s.Snippets.Search(t.Context(), "launch", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(results), 0)
+
+	draft.Content = "launch at dawn"
+	draft.Encrypted = false
+	if err := s.Snippets.Update(t.Context(), id, draft); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err = s.Snippets.Search(t.Context(), "launch", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].Encrypted, false)
+
+	// Encrypting a snippet drops its plaintext history.
+	draft.Content = ciphertext
+	draft.Encrypted = true
+	if err := s.Snippets.Update(t.Context(), id, draft); err != nil {
+		t.Fatal(err)
+	}
+
+	revisions, err := s.Snippets.Revisions(t.Context(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(revisions), 1)
+	assert.Equal(t, revisions[0].Number, 1)
+	assert.Equal(t, revisions[0].Content, ciphertext)
+}
+
+func testTokens(t *testing.T, s Stores) {
+	alice := newUser(t, s, "Alice", "alice@example.com")
+	bob := newUser(t, s, "Bob", "bob@example.com")
+
+	token, err := s.Tokens.New(t.Context(), alice, "ci", []string{tokens.ScopeRead, tokens.ScopeWrite}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, token.Plaintext != "", true)
+
+	got, err := s.Tokens.Authenticate(t.Context(), token.Plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, got.ID, token.ID)
+	assert.Equal(t, got.UserID, alice)
+	assert.Equal(t, got.HasScope(tokens.ScopeWrite), true)
+
+	_, err = s.Tokens.Authenticate(t.Context(), token.Plaintext+"x")
+	assert.Equal(t, errors.Is(err, models.ErrInvalidCredentials), true)
+
+	expired, err := s.Tokens.New(t.Context(), alice, "old", []string{tokens.ScopeRead}, -time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Tokens.Authenticate(t.Context(), expired.Plaintext)
+	assert.Equal(t, errors.Is(err, models.ErrInvalidCredentials), true)
+
+	list, err := s.Tokens.ForUser(t.Context(), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(list), 2)
+	assert.Equal(t, list[0].ID, expired.ID)
+	assert.Equal(t, list[0].Plaintext, "")
+	assert.Equal(t, list[1].LastUsed.IsZero(), false)
+
+	err = s.Tokens.Revoke(t.Context(), bob, token.ID)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+	if err := s.Tokens.Revoke(t.Context(), alice, token.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Tokens.Authenticate(t.Context(), token.Plaintext)
+	assert.Equal(t, errors.Is(err, models.ErrInvalidCredentials), true)
+}
@@ -0,0 +1,185 @@
+package tokens
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yousifsabah0/snippets/internal/models"
+)
+
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// Every plaintext token starts with this prefix, which makes leaked tokens
+// easy to spot in logs and by secret scanners.
+const prefix = "snp_"
+
+// Token is a personal API token. Only the SHA-256 hash of the token is
+// stored; Plaintext is filled in by New and is never available again.
+type Token struct {
+	ID        int
+	UserID    int
+	Name      string
+	Scopes    []string
+	Plaintext string
+	Hash      []byte
+	Created   time.Time
+	Expires   time.Time
+	LastUsed  time.Time
+}
+
+// HasScope reports whether the token grants scope.
+func (t Token) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+// TokenStore is implemented by every storage backend for API tokens.
+type TokenStore interface {
+	New(ctx context.Context, userID int, name string, scopes []string, ttl time.Duration) (Token, error)
+	ForUser(ctx context.Context, userID int) ([]Token, error)
+	Revoke(ctx context.Context, userID, id int) error
+	Authenticate(ctx context.Context, plaintext string) (Token, error)
+}
+
+type TokenModel struct {
+	DB      *sql.DB
+	Dialect models.Dialect
+}
+
+// Generate creates a new random token without storing it.
+func Generate(userID int, name string, scopes []string, ttl time.Duration) (Token, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return Token{}, err
+	}
+
+	token := Token{
+		UserID:    userID,
+		Name:      name,
+		Scopes:    scopes,
+		Plaintext: prefix + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)),
+		Created:   time.Now().UTC(),
+	}
+	token.Expires = token.Created.Add(ttl)
+	token.Hash = Hash(token.Plaintext)
+
+	return token, nil
+}
+
+// Hash returns the value stored in place of a plaintext token.
+func Hash(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+// We'll use the New method to mint a token for a user. The returned token
+// is the only place its plaintext is ever available.
+func (m *TokenModel) New(ctx context.Context, userID int, name string, scopes []string, ttl time.Duration) (Token, error) {
+	token, err := Generate(userID, name, scopes, ttl)
+	if err != nil {
+		return Token{}, err
+	}
+
+	stmt := `INSERT INTO tokens (user_id, name, scopes, hash, created, expires) VALUES (?, ?, ?, ?, ?, ?)`
+	id, err := m.Dialect.InsertID(ctx, m.DB, stmt, token.UserID, token.Name, strings.Join(token.Scopes, ","), token.Hash, token.Created, token.Expires)
+	if err != nil {
+		return Token{}, err
+	}
+
+	token.ID = id
+
+	return token, nil
+}
+
+// We'll use the ForUser method to list the tokens of a user, newest first.
+// Expired tokens are included so that their owner can still see them.
+func (m *TokenModel) ForUser(ctx context.Context, userID int) ([]Token, error) {
+	var tokens []Token
+
+	stmt := `SELECT id, user_id, name, scopes, created, expires, last_used FROM tokens WHERE user_id = ? ORDER BY id DESC`
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.Rebind(stmt), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			token    Token
+			scopes   string
+			lastUsed sql.NullTime
+		)
+
+		if err := rows.Scan(&token.ID, &token.UserID, &token.Name, &scopes, &token.Created, &token.Expires, &lastUsed); err != nil {
+			return nil, err
+		}
+
+		token.Scopes = strings.Split(scopes, ",")
+		token.LastUsed = lastUsed.Time
+		tokens = append(tokens, token)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// We'll use the Revoke method to delete one of a user's tokens. Tokens of
+// other users are reported as missing.
+func (m *TokenModel) Revoke(ctx context.Context, userID, id int) error {
+	result, err := m.DB.ExecContext(ctx, m.Dialect.Rebind(`DELETE FROM tokens WHERE id = ? AND user_id = ?`), id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+// We'll use the Authenticate method to resolve a plaintext bearer token into
+// the token it belongs to, recording when it was last used. Unknown and
+// expired tokens return models.ErrInvalidCredentials.
+func (m *TokenModel) Authenticate(ctx context.Context, plaintext string) (Token, error) {
+	var (
+		token  Token
+		scopes string
+	)
+
+	stmt := `SELECT id, user_id, name, scopes, created, expires FROM tokens WHERE hash = ? AND expires > ?`
+	err := m.DB.QueryRowContext(ctx, m.Dialect.Rebind(stmt), Hash(plaintext), time.Now().UTC()).Scan(&token.ID, &token.UserID, &token.Name, &scopes, &token.Created, &token.Expires)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Token{}, models.ErrInvalidCredentials
+		}
+
+		return Token{}, err
+	}
+
+	token.Scopes = strings.Split(scopes, ",")
+	token.LastUsed = time.Now().UTC()
+
+	if _, err := m.DB.ExecContext(ctx, m.Dialect.Rebind(`UPDATE tokens SET last_used = ? WHERE id = ?`), token.LastUsed, token.ID); err != nil {
+		return Token{}, err
+	}
+
+	return token, nil
+}
@@ -1,14 +1,18 @@
 package users
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"strings"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/yousifsabah0/snippets/internal/models"
 	"golang.org/x/crypto/bcrypt"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
 // Define a new User struct. Notice how the field names and types align
@@ -21,25 +25,42 @@ type User struct {
 	Created        time.Time
 }
 
+// UserStore is implemented by every storage backend for users.
+type UserStore interface {
+	Insert(ctx context.Context, name, email, password string) error
+	Authenticate(ctx context.Context, email, password string) (int, error)
+	Exists(ctx context.Context, id int) (bool, error)
+}
+
+// DefaultCost is the bcrypt cost used to hash passwords unless a model is
+// configured with another one.
+const DefaultCost = 12
+
 // Define a new UserModel struct which wraps a database connection pool.
 type UserModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Dialect models.Dialect
+
+	// Cost is the bcrypt cost used to hash passwords, DefaultCost if zero.
+	Cost int
 }
 
 // We'll use the Insert method to add a new record to the "users" table.
-func (m *UserModel) Insert(name, email, password string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) error {
+	cost := m.Cost
+	if cost == 0 {
+		cost = DefaultCost
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 	if err != nil {
 		return err
 	}
 
-	stmt := `INSERT INTO users (name, email, hashed_password, created) VALUES (?, ?, ?, UTC_TIMESTAMP())`
-	if _, err = m.DB.Exec(stmt, name, email, string(hashedPassword)); err != nil {
-		var mysqlError *mysql.MySQLError
-		if errors.As(err, &mysqlError) {
-			if mysqlError.Number == 1062 && strings.Contains(mysqlError.Message, "users_uc_email") {
-				return models.ErrDuplicateEmail
-			}
+	stmt := `INSERT INTO users (name, email, hashed_password, created) VALUES (?, ?, ?, ?)`
+	if _, err = m.DB.ExecContext(ctx, m.Dialect.Rebind(stmt), name, email, string(hashedPassword), time.Now().UTC()); err != nil {
+		if isDuplicateEmail(err) {
+			return models.ErrDuplicateEmail
 		}
 		return err
 	}
@@ -47,17 +68,40 @@ func (m *UserModel) Insert(name, email, password string) error {
 	return nil
 }
 
+// isDuplicateEmail reports whether err is the unique constraint on
+// users.email failing, in the dialect of whichever driver returned it.
+func isDuplicateEmail(err error) bool {
+	var mysqlError *mysql.MySQLError
+	if errors.As(err, &mysqlError) {
+		return mysqlError.Number == 1062 && strings.Contains(mysqlError.Message, "users_uc_email")
+	}
+
+	// SQLite names the columns rather than the constraint that failed.
+	var sqliteError *sqlite.Error
+	if errors.As(err, &sqliteError) {
+		return sqliteError.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE && strings.Contains(sqliteError.Error(), "users.email")
+	}
+
+	var pgError *pgconn.PgError
+	if errors.As(err, &pgError) {
+		// 23505 is unique_violation.
+		return pgError.Code == "23505" && pgError.ConstraintName == "users_uc_email"
+	}
+
+	return false
+}
+
 // We'll use the Authenticate method to verify whether a user exists with
 // the provided email address and password. This will return the relevant
 // user ID if they do.
-func (m *UserModel) Authenticate(email, password string) (int, error) {
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
 	var (
 		id             int
 		hashedPassword []byte
 	)
 
 	stmt := `SELECT id, hashed_password FROM users WHERE email = ?`
-	if err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword); err != nil {
+	if err := m.DB.QueryRowContext(ctx, m.Dialect.Rebind(stmt), email).Scan(&id, &hashedPassword); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, models.ErrInvalidCredentials
 		}
@@ -77,11 +121,11 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 }
 
 // We'll use the Exists method to check if a user exists with a specific ID.
-func (m *UserModel) Exists(id int) (bool, error) {
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
 	var exists bool
 
 	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ?)"
-	err := m.DB.QueryRow(stmt, id).Scan(&exists)
+	err := m.DB.QueryRowContext(ctx, m.Dialect.Rebind(stmt), id).Scan(&exists)
 
 	return exists, err
 }
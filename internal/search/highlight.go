@@ -0,0 +1,140 @@
+package search
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Span is a run of text that either matches the query or does not.
+type Span struct {
+	Text  string
+	Match bool
+}
+
+// Fragment is an excerpt of a text split into spans, so that templates can
+// mark the matches up without the text being trusted as HTML.
+type Fragment []Span
+
+// fragmentRadius is roughly how many bytes of context are kept on each side
+// of a match.
+const fragmentRadius = 60
+
+// Mark splits the whole of text into spans, marking the words the terms
+// match.
+func Mark(text string, terms []string) Fragment {
+	var (
+		fragment Fragment
+		last     int
+	)
+
+	for _, t := range tokenize(text) {
+		if !matches(t.word, terms) {
+			continue
+		}
+
+		if t.start > last {
+			fragment = append(fragment, Span{Text: text[last:t.start]})
+		}
+		fragment = append(fragment, Span{Text: text[t.start:t.end], Match: true})
+		last = t.end
+	}
+
+	if last < len(text) {
+		fragment = append(fragment, Span{Text: text[last:]})
+	}
+
+	return fragment
+}
+
+// Highlight returns up to max excerpts of text around the words the terms
+// match, with an ellipsis wherever text was cut. When nothing matches, the
+// start of the text is returned instead, so that a result that only matched
+// on its title still shows something.
+func Highlight(text string, terms []string, max int) []Fragment {
+	type window struct{ start, end int }
+
+	var windows []window
+	for _, t := range tokenize(text) {
+		if !matches(t.word, terms) {
+			continue
+		}
+
+		// Matches close to the previous one extend its window rather than
+		// starting another.
+		if n := len(windows); n > 0 && t.start <= windows[n-1].end {
+			windows[n-1].end = wordEnd(text, t.end+fragmentRadius, t.end)
+			continue
+		}
+
+		if len(windows) == max {
+			break
+		}
+
+		windows = append(windows, window{wordStart(text, t.start-fragmentRadius, t.start), wordEnd(text, t.end+fragmentRadius, t.end)})
+	}
+
+	if len(windows) == 0 {
+		windows = append(windows, window{0, wordEnd(text, 2*fragmentRadius, 0)})
+	}
+
+	fragments := make([]Fragment, 0, len(windows))
+	for _, w := range windows {
+		var fragment Fragment
+		if w.start > 0 {
+			fragment = append(fragment, Span{Text: "…"})
+		}
+
+		fragment = append(fragment, Mark(text[w.start:w.end], terms)...)
+
+		if w.end < len(text) {
+			fragment = append(fragment, Span{Text: "…"})
+		}
+
+		fragments = append(fragments, fragment)
+	}
+
+	return fragments
+}
+
+// wordStart moves i forward, but not past limit, to the start of a word so
+// that excerpts do not begin halfway through one.
+func wordStart(text string, i, limit int) int {
+	if i <= 0 {
+		return 0
+	}
+
+	for i < limit && !utf8.RuneStart(text[i]) {
+		i++
+	}
+
+	for j := i; j < limit; {
+		r, size := utf8.DecodeRuneInString(text[j:])
+		if unicode.IsSpace(r) {
+			return j + size
+		}
+		j += size
+	}
+
+	return i
+}
+
+// wordEnd moves i back, but not before limit, to the end of a word.
+func wordEnd(text string, i, limit int) int {
+	if i >= len(text) {
+		return len(text)
+	}
+
+	for i > limit && !utf8.RuneStart(text[i]) {
+		i--
+	}
+
+	for j := i; j > limit; {
+		r, size := utf8.DecodeLastRuneInString(text[:j])
+		if unicode.IsSpace(r) {
+			return j - size
+		}
+		j -= size
+	}
+
+	return i
+}
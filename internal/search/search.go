@@ -0,0 +1,218 @@
+// Package search is a small in-process full-text index for the stores that
+// have no full-text search of their own, together with the query parsing and
+// highlighting that every store shares so results look the same whichever
+// one is in use.
+//
+// Every SQL backend searches with its own full-text index: FULLTEXT on
+// MySQL, FTS5 on SQLite and a tsvector on PostgreSQL. That leaves the
+// in-memory store, which holds a few snippets for development and tests and
+// rebuilds its index on every start. An embedded engine such as bleve would
+// add a large dependency tree and its own analysers, so its ranking and
+// prefix matching would drift from the SQL backends'. This index instead
+// ranks with BM25, the scoring SQLite uses, and matches prefixes as every
+// backend does.
+package search
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// MaxTerms is the most terms a query is cut down to.
+const MaxTerms = 10
+
+// TitleWeight is how many times more a term counts in a title than in the
+// content.
+const TitleWeight = 3
+
+// BM25 parameters.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Terms splits a query into the distinct lower-cased words it is made of,
+// dropping anything else, so that the result is safe to build a backend's
+// own query syntax from. Every term matches words it is a prefix of.
+func Terms(query string) []string {
+	var terms []string
+	for _, t := range tokenize(query) {
+		if !slices.Contains(terms, t.word) {
+			terms = append(terms, t.word)
+		}
+		if len(terms) == MaxTerms {
+			break
+		}
+	}
+
+	return terms
+}
+
+type token struct {
+	word       string
+	start, end int
+}
+
+// tokenize splits text into words of letters and digits, recording their
+// byte offsets in text.
+func tokenize(text string) []token {
+	var tokens []token
+
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			tokens = append(tokens, token{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+
+	if start >= 0 {
+		tokens = append(tokens, token{strings.ToLower(text[start:]), start, len(text)})
+	}
+
+	return tokens
+}
+
+// matches reports whether word is matched by any of the terms.
+func matches(word string, terms []string) bool {
+	for _, term := range terms {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Index ranks documents made of a title and content against a query with
+// BM25. It is not safe for concurrent use.
+type Index struct {
+	docs     map[int]document
+	postings map[string]map[int]struct{}
+	// totalLength is the sum of the weighted lengths of all documents.
+	totalLength int
+}
+
+type document struct {
+	// freqs holds the weighted frequency of every word in the document.
+	freqs  map[string]int
+	length int
+}
+
+// Hit is a document matching a query and its relevance.
+type Hit struct {
+	ID    int
+	Score float64
+}
+
+func NewIndex() *Index {
+	return &Index{
+		docs:     map[int]document{},
+		postings: map[string]map[int]struct{}{},
+	}
+}
+
+// Add indexes a document, replacing any earlier version of it.
+func (ix *Index) Add(id int, title, content string) {
+	ix.Remove(id)
+
+	doc := document{freqs: map[string]int{}}
+	for _, t := range tokenize(title) {
+		doc.freqs[t.word] += TitleWeight
+		doc.length += TitleWeight
+	}
+	for _, t := range tokenize(content) {
+		doc.freqs[t.word]++
+		doc.length++
+	}
+
+	for word := range doc.freqs {
+		if ix.postings[word] == nil {
+			ix.postings[word] = map[int]struct{}{}
+		}
+		ix.postings[word][id] = struct{}{}
+	}
+
+	ix.docs[id] = doc
+	ix.totalLength += doc.length
+}
+
+// Remove drops a document from the index.
+func (ix *Index) Remove(id int) {
+	doc, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+
+	for word := range doc.freqs {
+		delete(ix.postings[word], id)
+		if len(ix.postings[word]) == 0 {
+			delete(ix.postings, word)
+		}
+	}
+
+	delete(ix.docs, id)
+	ix.totalLength -= doc.length
+}
+
+// Search returns the documents matching any of the terms, most relevant
+// first and newest first among equals.
+func (ix *Index) Search(terms []string) []Hit {
+	if len(terms) == 0 || len(ix.docs) == 0 {
+		return nil
+	}
+
+	n := float64(len(ix.docs))
+	avgLength := float64(ix.totalLength) / n
+
+	scores := map[int]float64{}
+	for _, term := range terms {
+		// A term matches every word it is a prefix of, and each of those
+		// words is scored on its own.
+		for word, ids := range ix.postings {
+			if !strings.HasPrefix(word, term) {
+				continue
+			}
+
+			df := float64(len(ids))
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+			for id := range ids {
+				doc := ix.docs[id]
+				tf := float64(doc.freqs[word])
+				scores[id] += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*float64(doc.length)/avgLength))
+			}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+
+	slices.SortFunc(hits, func(a, b Hit) int {
+		if c := -compareFloat(a.Score, b.Score); c != 0 {
+			return c
+		}
+		return b.ID - a.ID
+	})
+
+	return hits
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,112 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/yousifsabah0/snippets/internal/assert"
+)
+
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"Words", "Old Silent pond", "[old silent pond]"},
+		{"Punctuation and operators", `"pond" -frog +water* OR (leap)`, "[pond frog water or leap]"},
+		{"Duplicates", "pond Pond POND", "[pond]"},
+		{"Nothing", " *** ", "[]"},
+		{"Too many", "a b c d e f g h i j k l", "[a b c d e f g h i j]"},
+		{"Unicode", "Ünïcode 漢字", "[ünïcode 漢字]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, fmt.Sprint(Terms(tt.query)), tt.want)
+		})
+	}
+}
+
+func TestIndex(t *testing.T) {
+	ix := NewIndex()
+	ix.Add(1, "An old silent pond", "A frog jumps into the pond, splash! Silence again.")
+	ix.Add(2, "Over the wintry forest", "Over the wintry forest, winds howl in rage with no leaves to blow.")
+	ix.Add(3, "First autumn morning", "The mirror I stare into shows my father's face.")
+
+	ids := func(hits []Hit) []int {
+		var ids []int
+		for _, hit := range hits {
+			ids = append(ids, hit.ID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, fmt.Sprint(ids(ix.Search([]string{"pond"}))), "[1]")
+	// Prefix matching finds silent and silence.
+	assert.Equal(t, fmt.Sprint(ids(ix.Search([]string{"silen"}))), "[1]")
+	// Any term may match; more matches rank higher.
+	assert.Equal(t, fmt.Sprint(ids(ix.Search([]string{"into", "frog"}))), "[1 3]")
+	// A title match outweighs one in the content.
+	assert.Equal(t, fmt.Sprint(ids(ix.Search([]string{"forest", "face"}))), "[2 3]")
+	assert.Equal(t, len(ix.Search([]string{"nothing"})), 0)
+	assert.Equal(t, len(ix.Search(nil)), 0)
+
+	ix.Add(1, "Replaced", "no frogs here")
+	assert.Equal(t, len(ix.Search([]string{"pond"})), 0)
+	assert.Equal(t, fmt.Sprint(ids(ix.Search([]string{"frog"}))), "[1]")
+
+	ix.Remove(1)
+	assert.Equal(t, len(ix.Search([]string{"frog"})), 0)
+	assert.Equal(t, len(ix.postings["frogs"]), 0)
+}
+
+// render writes a fragment out with matches in brackets.
+func render(fragments ...Fragment) string {
+	var sb strings.Builder
+	for i, fragment := range fragments {
+		if i > 0 {
+			sb.WriteString(" | ")
+		}
+		for _, span := range fragment {
+			if span.Match {
+				sb.WriteString("[" + span.Text + "]")
+			} else {
+				sb.WriteString(span.Text)
+			}
+		}
+	}
+	return sb.String()
+}
+
+func TestHighlight(t *testing.T) {
+	assert.Equal(t, render(Mark("An old silent pond", []string{"old", "pon"})), "An [old] silent [pond]")
+	assert.Equal(t, render(Mark("no match", []string{"pond"})), "no match")
+
+	long := strings.Repeat("filler ", 30) + "needle " + strings.Repeat("filler ", 30) + "needle again " + strings.Repeat("filler ", 30)
+
+	fragments := Highlight(long, []string{"needle"}, 3)
+	assert.Equal(t, len(fragments), 2)
+	for _, fragment := range fragments {
+		got := render(fragment)
+		assert.Equal(t, strings.HasPrefix(got, "…filler"), true)
+		assert.Equal(t, strings.HasSuffix(got, "filler…"), true)
+		assert.StringContains(t, got, "[needle]")
+	}
+
+	assert.Equal(t, len(Highlight(long, []string{"needle"}, 1)), 1)
+
+	// Nearby matches share a fragment.
+	assert.Equal(t, render(Highlight("a needle and a needle", []string{"needle"}, 3)...), "a [needle] and a [needle]")
+
+	// Without a match the start of the text is shown.
+	assert.Equal(t, render(Highlight(long, []string{"haystack"}, 3)...), strings.TrimSpace(strings.Repeat("filler ", 17))+"…")
+
+	// Cuts never split a multi-byte character.
+	for _, fragment := range Highlight(strings.Repeat("ééé", 100)+" needle", []string{"needle"}, 1) {
+		for _, span := range fragment {
+			assert.Equal(t, strings.ToValidUTF8(span.Text, "?"), span.Text)
+		}
+	}
+}
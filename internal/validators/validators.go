@@ -1,12 +1,18 @@
 package validators
 
 import (
+	"cmp"
 	"regexp"
 	"slices"
 	"strings"
+	"time"
 	"unicode/utf8"
 )
 
+// TagRX matches a tag: lowercase letters and digits, and after the first
+// character also dots, underscores, pluses and hyphens.
+var TagRX = regexp.MustCompile(`^[a-z0-9][a-z0-9._+-]*$`)
+
 var EmailRx = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
 type Validator struct {
@@ -54,6 +60,60 @@ func Matches(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
 }
 
+// Between returns true if value is within [lo, hi].
+func Between[T cmp.Ordered](value, lo, hi T) bool {
+	return value >= lo && value <= hi
+}
+
+// MaxItems returns true if values holds no more than n items.
+func MaxItems[T any](values []T, n int) bool {
+	return len(values) <= n
+}
+
+// AllMaxChars returns true if none of values is longer than n characters.
+func AllMaxChars(values []string, n int) bool {
+	for _, value := range values {
+		if !MaxChars(value, n) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AllMatch returns true if every one of values matches rx.
+func AllMatch(values []string, rx *regexp.Regexp) bool {
+	for _, value := range values {
+		if !Matches(value, rx) {
+			return false
+		}
+	}
+
+	return true
+}
+
 func PermittedValue[T comparable](value T, permittedValues ...T) bool {
 	return slices.Contains(permittedValues, value)
 }
+
+func PermittedValues[T comparable](values []T, permittedValues ...T) bool {
+	for _, value := range values {
+		if !PermittedValue(value, permittedValues...) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParseDate parses an optional date in the YYYY-MM-DD form used by date
+// inputs. An empty value gives the zero time; ok is false if it is malformed.
+func ParseDate(value string) (t time.Time, ok bool) {
+	if value == "" {
+		return time.Time{}, true
+	}
+
+	t, err := time.Parse(time.DateOnly, value)
+
+	return t, err == nil
+}
@@ -5,4 +5,219 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+
+// Line anchors on highlighted snippets: #L10 marks a line and #L10-L20 a
+// range of them. Shift-clicking a line number extends the current line into
+// a range.
+var lineRX = /^#L(\d+)(?:-L(\d+))?$/;
+
+function markLines(scroll) {
+	var marked = document.querySelectorAll(".code .line.hl");
+	for (var i = 0; i < marked.length; i++) {
+		marked[i].classList.remove("hl");
+	}
+
+	var match = lineRX.exec(window.location.hash);
+	if (!match) {
+		return;
+	}
+
+	var from = parseInt(match[1], 10);
+	var to = match[2] ? parseInt(match[2], 10) : from;
+	if (to < from) {
+		var swap = from;
+		from = to;
+		to = swap;
+	}
+
+	for (var n = from; n <= to; n++) {
+		var number = document.getElementById("L" + n);
+		if (number) {
+			number.parentNode.classList.add("hl");
+		}
+	}
+
+	// Browsers only scroll to single lines by themselves, as ranges have no
+	// element with their ID.
+	var first = document.getElementById("L" + from);
+	if (scroll && first && match[2]) {
+		first.scrollIntoView();
+	}
+}
+
+document.addEventListener("click", function (event) {
+	var link = event.target.closest ? event.target.closest(".code a.lnlinks") : null;
+	if (!link || !event.shiftKey) {
+		return;
+	}
+
+	var match = lineRX.exec(window.location.hash);
+	if (!match) {
+		return;
+	}
+
+	event.preventDefault();
+
+	var from = parseInt(match[1], 10);
+	var to = parseInt(link.getAttribute("href").slice(2), 10);
+	var range = "#L" + Math.min(from, to) + "-L" + Math.max(from, to);
+
+	history.replaceState(null, "", range);
+	markLines(false);
+});
+
+window.addEventListener("hashchange", function () {
+	markLines(true);
+});
+
+markLines(true);
+
+// Encrypted snippets are sealed with AES-GCM in the browser before they are
+// sent, under a key that only ever travels in the URL fragment as #key=...
+// Browsers never send the fragment to the server, and keep it across
+// redirects that do not set one of their own.
+var keyRX = /^#key=([A-Za-z0-9_-]+)$/;
+var nonceSize = 12;
+
+function toBase64(bytes) {
+	var s = "";
+	for (var i = 0; i < bytes.length; i++) {
+		s += String.fromCharCode(bytes[i]);
+	}
+	return btoa(s);
+}
+
+function fromBase64(s) {
+	var raw = atob(s);
+	var bytes = new Uint8Array(raw.length);
+	for (var i = 0; i < raw.length; i++) {
+		bytes[i] = raw.charCodeAt(i);
+	}
+	return bytes;
+}
+
+// The key is base64url encoded, as it has to fit in a URL.
+function keyToFragment(raw) {
+	return "#key=" + toBase64(new Uint8Array(raw)).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+// fragmentKey resolves to the key in the URL fragment, or to null when
+// there is none.
+function fragmentKey() {
+	var match = keyRX.exec(window.location.hash);
+	if (!match) {
+		return Promise.resolve(null);
+	}
+
+	return Promise.resolve().then(function () {
+		var s = match[1].replace(/-/g, "+").replace(/_/g, "/");
+		while (s.length % 4) {
+			s += "=";
+		}
+		return crypto.subtle.importKey("raw", fromBase64(s), "AES-GCM", true, ["encrypt", "decrypt"]);
+	});
+}
+
+// encrypt resolves to the base64 of a fresh nonce followed by the sealed
+// text, the only form of encrypted content the server accepts.
+function encrypt(key, text) {
+	var nonce = crypto.getRandomValues(new Uint8Array(nonceSize));
+	return crypto.subtle.encrypt({ name: "AES-GCM", iv: nonce }, key, new TextEncoder().encode(text)).then(function (sealed) {
+		var blob = new Uint8Array(nonce.length + sealed.byteLength);
+		blob.set(nonce);
+		blob.set(new Uint8Array(sealed), nonce.length);
+		return toBase64(blob);
+	});
+}
+
+function decrypt(key, ciphertext) {
+	return Promise.resolve().then(function () {
+		if (!key) {
+			throw new Error("no key");
+		}
+
+		var blob = fromBase64(ciphertext);
+		return crypto.subtle.decrypt({ name: "AES-GCM", iv: blob.subarray(0, nonceSize) }, key, blob.subarray(nonceSize));
+	}).then(function (text) {
+		return new TextDecoder().decode(text);
+	});
+}
+
+var decryptError = "This snippet could not be decrypted. Check that its link is complete, including the key after #key=.";
+
+var sealed = document.querySelector(".code[data-ciphertext]");
+if (sealed) {
+	fragmentKey().then(function (key) {
+		return decrypt(key, sealed.getAttribute("data-ciphertext"));
+	}).then(function (text) {
+		var pre = document.createElement("pre");
+		pre.textContent = text;
+		sealed.replaceChildren(pre);
+	}).catch(function () {
+		sealed.querySelector(".notice").textContent = decryptError;
+	});
+}
+
+// Links and forms that lead to the same snippet carry the key along.
+if (keyRX.test(window.location.hash)) {
+	var keepers = document.querySelectorAll("[data-keep-key]");
+	for (var i = 0; i < keepers.length; i++) {
+		var keeper = keepers[i];
+		if (keeper.tagName == "FORM") {
+			keeper.action = keeper.getAttribute("action") + window.location.hash;
+		} else {
+			if (keeper.textContent == keeper.getAttribute("href")) {
+				keeper.textContent += window.location.hash;
+			}
+			keeper.href = keeper.getAttribute("href") + window.location.hash;
+		}
+	}
+}
+
+// The create and edit forms encrypt the content on the way out when asked
+// to, keeping the key of a snippet that already has one so that its links
+// keep working. Editing an encrypted snippet starts by decrypting it.
+var encryptForm = document.querySelector("form[data-encrypt]");
+if (encryptForm) {
+	var content = encryptForm.querySelector("textarea[name=content]");
+	var encrypted = encryptForm.querySelector("input[name=encrypted]");
+
+	if (encrypted.checked && content.value) {
+		content.disabled = true;
+		fragmentKey().then(function (key) {
+			return decrypt(key, content.value);
+		}).then(function (text) {
+			content.value = text;
+			content.disabled = false;
+		}).catch(function () {
+			var error = document.createElement("label");
+			error.className = "error";
+			error.textContent = decryptError;
+			content.parentNode.insertBefore(error, content);
+		});
+	}
+
+	encryptForm.addEventListener("submit", function (event) {
+		if (!encrypted.checked) {
+			return;
+		}
+
+		event.preventDefault();
+
+		// Content that could not be decrypted cannot be saved either.
+		if (content.disabled) {
+			return;
+		}
+
+		fragmentKey().then(function (key) {
+			return key || crypto.subtle.generateKey({ name: "AES-GCM", length: 256 }, true, ["encrypt", "decrypt"]);
+		}).then(function (key) {
+			return Promise.all([encrypt(key, content.value), crypto.subtle.exportKey("raw", key)]);
+		}).then(function (results) {
+			content.value = results[0];
+			encryptForm.action = encryptForm.getAttribute("action") + keyToFragment(results[1]);
+			encryptForm.submit();
+		});
+	});
+}
@@ -1,6 +1,20 @@
 package web
 
-import "embed"
+import (
+	"embed"
+	"io/fs"
+)
 
 //go:embed "assets" "app"
 var Files embed.FS
+
+// Assets returns the static files, which are served under /static/.
+func Assets() fs.FS {
+	assets, err := fs.Sub(Files, "assets")
+	if err != nil {
+		// fs.Sub only fails on invalid paths, and "assets" is not one.
+		panic(err)
+	}
+
+	return assets
+}